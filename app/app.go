@@ -3,52 +3,268 @@ package app
 import (
 	"bytes"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
+	"io"
+	"net/http"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mcnull/qai/providers/github"
-	"github.com/mcnull/qai/providers/ollama"
+	"github.com/mcnull/qai/shared/cassette"
+	"github.com/mcnull/qai/shared/desktop"
+	"github.com/mcnull/qai/shared/httpclient"
+	"github.com/mcnull/qai/shared/logging"
 	"github.com/mcnull/qai/shared/markdown"
 	"github.com/mcnull/qai/shared/platform"
 	"github.com/mcnull/qai/shared/provider"
+	"github.com/mcnull/qai/shared/schema"
+	"github.com/mcnull/qai/shared/terminal"
 	"github.com/mcnull/qai/shared/throbber"
+	"github.com/mcnull/qai/shared/ui"
 	"github.com/mcnull/qai/shared/utils"
 )
 
 type App struct {
 	provider.AppContext
-	Config *Config
+	Config  *Config
+	Profile *Profile
+	// The name of Profile, which differs from the -profile flag after a fallback
+	profileName string
+	// The profiles that are tried next when the provider fails, see Config.FallbackChain
+	fallbacks []string
+	// The profiles that are asked at once with --compare
+	comparisons []*comparison
+
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Stderr    io.Writer
+	LookupEnv func(key string) (string, bool)
+	Environ   func() []string
+	Getwd     func() (string, error)
+	Now       func() time.Time
+
+	// Set when a subcommand is run instead of a prompt
+	command     *command
+	commandArgs []string
+
+	// The directory of the system config, "" for the one of the platform
+	systemDir string
+	// Interrupts, nil for SIGINT and SIGTERM, see notifyContext
+	signals <-chan os.Signal
+	// The file of --log-file, closed when Main returns
+	logFile io.Closer
+	// Closed on a second interrupt, see forceExit
+	forced    chan struct{}
+	forceOnce sync.Once
 }
 
 func NewApp() *App {
 	return &App{
 		AppContext: provider.AppContext{
 			Flags: provider.NewFlagValues(
-				DEFAULT_CONFIG_FILEPATH,
-				DEFAULT_SYSTEM_PROMPT,
+				"", // The default depends on the environment, see parseArgs
+				"", // The system prompt of the config is used by default
 			),
 			Provider: nil,
+			UI:       ui.New(os.Stderr),
+			Logger:   logging.Discard(),
 		},
-		Config: nil, // Config will be initialized later
+		Config:    nil, // Config will be initialized later
+		Profile:   nil, // Profile is selected when the provider is initialized
+		Stdin:     os.Stdin,
+		Stdout:    os.Stdout,
+		Stderr:    os.Stderr,
+		LookupEnv: os.LookupEnv,
+		Environ:   os.Environ,
+		Getwd:     os.Getwd,
+		Now:       time.Now,
+		forced:    make(chan struct{}),
 	}
 }
 
+// WithIO sets where the prompt is read from and where the answer and diagnostics are written.
+func (app *App) WithIO(stdin io.Reader, stdout, stderr io.Writer) *App {
+	app.Stdin = stdin
+	app.Stdout = stdout
+	app.Stderr = stderr
+	app.UI = ui.New(stderr)
+	return app
+}
+
+// WithEnv sets the environment, as KEY=value entries, used for flags, colors, the config
+// and the default config path.
+func (app *App) WithEnv(environ []string) *App {
+	env := map[string]string{}
+
+	for _, entry := range environ {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			env[key] = value
+		}
+	}
+
+	app.LookupEnv = func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+	app.Environ = func() []string {
+		return slices.Clone(environ)
+	}
+
+	return app
+}
+
+// WithWorkDir sets the directory the project config file is looked up from.
+func (app *App) WithWorkDir(dir string) *App {
+	app.Getwd = func() (string, error) {
+		return dir, nil
+	}
+	return app
+}
+
+// WithSystemConfigDir sets the directory the system config file is read from.
+func (app *App) WithSystemConfigDir(dir string) *App {
+	app.systemDir = dir
+	return app
+}
+
+// WithSignals sets where interrupts are received from instead of SIGINT and SIGTERM.
+func (app *App) WithSignals(signals <-chan os.Signal) *App {
+	app.signals = signals
+	return app
+}
+
+// WithClock sets the clock used for timings and the usage ledger.
+func (app *App) WithClock(now func() time.Time) *App {
+	app.Now = now
+	return app
+}
+
+func (app *App) getenv(key string) string {
+	value, _ := app.LookupEnv(key)
+	return value
+}
+
+func (app *App) defaultConfigFilePath() string {
+	return defaultConfigFilePath(app.getenv)
+}
+
+// Main runs qai with the command line args and returns the process exit code.
+func (app *App) Main(args []string) int {
+	defer app.closeLog()
+
+	code := make(chan int, 1)
+
+	go func() {
+		code <- app.main(args)
+	}()
+
+	// A second interrupt doesn't wait for a request that is slow to stop
+	select {
+	case c := <-code:
+		return c
+	case <-app.forced:
+		// Leave the terminal on a clean line
+		fmt.Fprintln(app.Stderr)
+		return EXIT_CANCELLED
+	}
+}
+
+func (app *App) main(args []string) int {
+	c, err := app.Init(args)
+
+	if err != nil {
+		return app.exit("Error initializing", err)
+	}
+
+	if !c {
+		return EXIT_OK
+	}
+
+	err = app.Run()
+
+	if err != nil {
+		return app.exit("Error", err)
+	}
+
+	return EXIT_OK
+}
+
+func (app *App) exit(prefix string, err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return EXIT_OK
+	}
+
+	var uErr *usageError
+
+	// Cancellations and usage errors have already been reported
+	if !errors.Is(err, ErrCancelled) && !errors.As(err, &uErr) {
+		PrintError(app.Stderr, prefix, err)
+	}
+
+	return ExitCode(err)
+}
+
 func (app *App) parseArgs(args []string) error {
 
+	if app.Flags.ConfigFile == "" {
+		app.Flags.ConfigFile = app.defaultConfigFilePath()
+	}
+
 	// Parse arguments
 
-	flags, err := parseFlags(args[1:], true, app.Flags)
+	flags, err := parseFlags(args[1:], false, app.Flags, app.LookupEnv, app.Stderr)
+
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
 
 	if err != nil {
 		err = fmt.Errorf("error parsing flags: %w", err)
-		return err
+		return &usageError{err: err}
+	}
+
+	if (flags.Timeout != nil && *flags.Timeout < 0) || (flags.FirstTokenTimeout != nil && *flags.FirstTokenTimeout < 0) {
+		return app.usageErrorf("-timeout and -first-token-timeout can't be negative")
 	}
 
 	app.Flags = flags
 
+	err = app.initLogger()
+	if err != nil {
+		return err
+	}
+
+	var transport http.RoundTripper = http.DefaultTransport
+
+	if flags.Replay != "" {
+		replayer, err := cassette.LoadReplayer(flags.Replay)
+		if err != nil {
+			return err
+		}
+
+		transport = replayer
+	}
+
+	if flags.Record != "" {
+		recorder, err := cassette.NewRecorder(flags.Record, transport)
+		if err != nil {
+			return err
+		}
+
+		app.UI.Printf("Recording HTTP interactions to %s\n", recorder.Path())
+		transport = recorder
+	}
+
+	// Every request is traced when the log level is debug
+	app.HTTPClient = httpclient.New(flags.Retries).
+		WithTransport(logging.NewTransport(transport, app.Logger))
+
 	return nil
 }
 
@@ -59,7 +275,7 @@ func (app *App) initConfig() (bool, error) {
 	// Check if we need to create a new config file
 
 	if flags.CreateConfig {
-		_, err := createNewConfigFile(flags.ConfigFile)
+		_, err := createNewConfigFile(flags.ConfigFile, app.UI)
 
 		if err != nil {
 			return false, err
@@ -68,48 +284,70 @@ func (app *App) initConfig() (bool, error) {
 		return false, nil
 	}
 
-	// Load config
-	config, err := LoadConfig(flags.ConfigFile)
+	// Load the config layers
+	layers := app.configLayers()
 
-	if err != nil {
+	if !findLayer(layers, LAYER_USER).exists() {
+		if flags.ConfigFile != app.defaultConfigFilePath() {
+			return false, fmt.Errorf("error loading config: %s does not exist", flags.ConfigFile)
+		}
 
-		// If the config file does not exist and the config file is set to the default:
-		if os.IsNotExist(err) && flags.ConfigFile == DEFAULT_CONFIG_FILEPATH {
-			// create a new config file
-			fmt.Println("Config file does not exist, creating a new one...")
-			config, err = createNewConfigFile(flags.ConfigFile)
+		// A new user config holds every default, it would override the system and project configs
+		if !findLayer(layers, LAYER_SYSTEM).exists() && !findLayer(layers, LAYER_PROJECT).exists() {
+			app.UI.Println("Config file does not exist, creating a new one...")
 
-			if err != nil {
+			if _, err := createNewConfigFile(flags.ConfigFile, app.UI); err != nil {
 				return false, err
 			}
-		} else {
-			err = fmt.Errorf("error loading config: %w", err)
-			return false, err
+
+			layers = app.configLayers()
 		}
 	}
 
+	if user := findLayer(layers, LAYER_USER); user.Found && user.Error == "" && user.version < CONFIG_VERSION {
+		app.upgradeConfig()
+	}
+
+	if project := findLayer(layers, LAYER_PROJECT); len(project.Ignored) > 0 {
+		app.Logger.Warn("ignoring keys that a project config can't set, set them in the user config instead",
+			"path", project.Source, "keys", strings.Join(project.Ignored, ", "))
+	}
+
+	config, err := mergeLayers(layers)
+	if err != nil {
+		return false, fmt.Errorf("error loading config: %w", err)
+	}
+
 	app.Config = config
 
 	// Check if we need to login to GitHub
 	if flags.GithubLogin {
-		token, err := github.Login(flags.Debug)
+		ghConfig, ok := app.Config.Providers.GitHub.(*github.Config)
 
-		if err != nil {
-			err = fmt.Errorf("error logging in to GitHub: %w", err)
+		if !ok {
+			err = fmt.Errorf("error casting config to GitHub config")
 			return false, err
 		}
 
-		// Store the token in the config
-		ghConfig, ok := app.Config.Providers.GitHub.(*github.Config)
+		ctx, stop := app.notifyContext(context.Background())
+		token, err := github.Login(ctx, app.HTTPClient, ghConfig.Endpoints(), app.UI, app.Logger)
+		cancelled := isCancelled(ctx)
+		stop()
 
-		if !ok {
-			err = fmt.Errorf("error casting config to GitHub config")
+		if cancelled {
+			return false, ErrCancelled
+		}
+
+		if err != nil {
+			err = fmt.Errorf("error logging in to GitHub: %w", err)
 			return false, err
 		}
 
+		// Store the token in the user config, the other layers are left as they are
+
 		ghConfig.Token = token
 
-		err = app.Config.Save(flags.ConfigFile)
+		err = setConfigValue(flags.ConfigFile, []string{"providers", "github", "token"}, token)
 
 		if err != nil {
 			err = fmt.Errorf("error saving config: %w", err)
@@ -125,74 +363,105 @@ func (app *App) initConfig() (bool, error) {
 	return true, nil
 }
 
+// upgradeConfig migrates the user config file to the current version of the format.
+func (app *App) upgradeConfig() {
+	file := app.Flags.ConfigFile
+
+	version, backup, err := upgradeConfigFile(file)
+
+	if err != nil {
+		// The config has already been migrated in memory, a read-only file still works
+		app.Logger.Warn("unable to upgrade config file", "path", file, "error", err.Error())
+		return
+	}
+
+	if backup != "" {
+		app.UI.Printf("Upgraded %s from version %d to %d, the original is saved as %s\n", file, version, CONFIG_VERSION, backup)
+	}
+}
+
 func (app *App) initProvider() error {
 
-	// Get the profile from the config
+	chain, err := app.Config.FallbackChain(app.Flags.Profile)
 
-	profile, err := app.Config.GetProfile(app.Flags.Profile)
+	if err != nil {
+		err = fmt.Errorf("error getting profile: %w", err)
+		return err
+	}
+
+	app.fallbacks = chain
+
+	return app.nextProvider(nil)
+}
+
+// useProfile creates and initializes the provider of the named profile and uses it.
+func (app *App) useProfile(name string) error {
+
+	p, profile, err := app.newProvider(name)
 
 	if err != nil {
-		err = fmt.Errorf("error getting profile \"%s\" from config: %w", app.Flags.Profile, err)
 		return err
 	}
 
-	if app.Flags.Debug {
-		fmt.Println("Profile:")
-		utils.Dump(profile)
+	app.Provider = p
+	app.Profile = profile
+	app.profileName = name
+
+	return nil
+}
+
+// newProvider creates and initializes the provider of the named profile.
+func (app *App) newProvider(name string) (provider.IProvider, *Profile, error) {
+
+	// Get the profile from the config
+
+	profile, err := app.Config.GetProfile(name)
+
+	if err != nil {
+		err = fmt.Errorf("error getting profile: %w", err)
+		return nil, nil, err
 	}
 
-	var pConfig provider.IConfig              // default from config
-	var pConfigFactory provider.ConfigFactory // config factory
-	var pFactory provider.ProviderFactory     // provider factory
+	app.Logger.Debug("profile", "name", name, "profile", logging.JSON(profile))
 
-	switch profile.Provider {
+	if err := profile.checkTimeouts(); err != nil {
+		return nil, nil, fmt.Errorf("error in profile \"%s\": %w", name, err)
+	}
 
-	case "ollama":
-		pConfig = app.Config.Providers.Ollama
-		pConfigFactory = ollama.NewConfig
-		pFactory = ollama.NewOllamaProvider
-		break
+	factories, ok := providerFactories[profile.Provider]
 
-	case "github":
-		pConfig = app.Config.Providers.GitHub
-		pConfigFactory = github.NewConfig
-		pFactory = github.NewGitHubProvider
-		break
+	if !ok {
+		return nil, nil, fmt.Errorf("error in profile \"%s\": %s", name, unknownProviderMessage(profile.Provider))
 	}
 
-	pConfig, err = provider.InitConfig(
-		pConfig,
-		pConfigFactory,
+	pConfig, err := provider.InitConfig(
+		app.Config.Providers.Get(profile.Provider),
+		factories.config,
 		profile.Settings,
 	)
 
 	if err != nil {
 		err = fmt.Errorf("error initializing provider config: %w", err)
-		return err
+		return nil, nil, err
 	}
 
-	if app.Flags.Debug {
-		fmt.Println("Provider config:")
-		utils.Dump(pConfig)
-	}
+	app.Logger.Debug("provider config", "provider", profile.Provider, "config", logging.JSON(pConfig))
 
-	var p provider.IProvider
-	p, err = pFactory(pConfig, &app.AppContext)
+	p, err := factories.provider(pConfig, &app.AppContext)
 
 	if err != nil {
 		err = fmt.Errorf("error creating provider: %w", err)
+		return nil, nil, err
 	}
 
 	err = p.Init()
 
 	if err != nil {
 		err = fmt.Errorf("error initializing provider: %w", err)
-		return err
+		return nil, nil, err
 	}
 
-	app.Provider = p
-
-	return nil
+	return p, profile, nil
 }
 
 func (app *App) Init(args []string) (bool, error) {
@@ -207,14 +476,20 @@ func (app *App) Init(args []string) (bool, error) {
 		return false, err
 	}
 
-	utils.DumpInColor = app.Flags.Color
+	utils.DumpInColor = terminal.ColorEnabled(app.Flags.Color, app.Stderr, app.getenv)
 
 	if app.Flags.Version {
-		fmt.Printf("%s %s (%s)\n", APP_NAME, APP_VERSION, "https://github.com/mcnull/qai")
-		fmt.Printf("Config file: %s\n", app.Flags.ConfigFile)
+		fmt.Fprintf(app.Stdout, "%s %s (%s)\n", APP_NAME, APP_VERSION, "https://github.com/mcnull/qai")
+		fmt.Fprintf(app.Stdout, "Config file: %s\n", app.Flags.ConfigFile)
 		return false, nil
 	}
 
+	app.command, app.commandArgs = findCommand(app.Flags.Args)
+
+	if app.command != nil && (app.command.noConfig || app.command.run == nil) {
+		return true, nil
+	}
+
 	// Load config
 
 	c, err = app.initConfig()
@@ -227,6 +502,23 @@ func (app *App) Init(args []string) (bool, error) {
 		return false, nil
 	}
 
+	// Subcommands don't talk to a provider
+
+	if app.command != nil {
+		return true, nil
+	}
+
+	// A comparison asks the providers of several profiles
+
+	if app.Flags.Compare != "" {
+		err = app.initCompare()
+		if err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
 	// Initialize provider
 
 	err = app.initProvider()
@@ -239,9 +531,13 @@ func (app *App) Init(args []string) (bool, error) {
 
 func (app *App) getSystemPrompt() (string, error) {
 
+	// The -system flag overrides the system prompt of the config
 	system := app.Flags.System
+	if system == "" && app.Config != nil {
+		system = app.Config.System
+	}
 	if system == "" {
-		system = app.SystemPrompt
+		system = DEFAULT_SYSTEM_PROMPT
 	}
 
 	platformInfo, err := platform.GetInfo()
@@ -278,111 +574,274 @@ func (app *App) getSystemPrompt() (string, error) {
 
 func (app *App) Run() error {
 
+	if app.command != nil {
+		if app.command.run == nil {
+			printCommands(app.Stderr, app.command)
+			return &usageError{err: fmt.Errorf("missing %s command", app.command.name)}
+		}
+
+		return app.command.run(app, app.commandArgs)
+	}
+
+	// A prompt of "-" is read from stdin
+	if app.Flags.Prompt == "-" {
+		prompt, err := io.ReadAll(app.Stdin)
+		if err != nil {
+			return fmt.Errorf("error reading prompt from stdin: %w", err)
+		}
+		app.Flags.Prompt = strings.TrimSpace(string(prompt))
+	}
+
 	// Check if prompt is empty
 	if app.Flags.Prompt == "" {
-		fmt.Println("No prompt provided")
-		fmt.Println("Use -h or --help for more information")
+		app.UI.Println("No prompt provided")
+		app.UI.Println("Use -h or --help for more information")
 		return nil
 	}
 
 	system, err := app.getSystemPrompt()
 	if err != nil {
-		err = fmt.Errorf("error getting system prompt: %w", err)
+		return fmt.Errorf("error getting system prompt: %w", err)
+	}
+
+	if app.comparisons != nil {
+		return app.runCompare(system)
+	}
+
+	sch, err := app.loadSchema()
+	if err != nil {
+		return err
+	}
+
+	out, err := app.newOutput(app.outputMode(), app.Stdout)
+	if err != nil {
+		return err
 	}
 
 	request := &provider.GenerateRequest{
 		System: system,
 		Prompt: app.Flags.Prompt,
+		Stream: out.Streams(),
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	if sch != nil {
+		// The answer is only written once it has been validated
+		out = &bufferedOutput{output: out}
+
+		request.Stream = false
+		request.Schema = sch.Raw
+	}
+
+	ctx, stop := app.notifyContext(context.Background())
+	defer stop()
+
+	for {
+		result := newResult(app.Provider.GetName(), app.profileName, "", app.Now())
+
+		err = app.ask(ctx, *request, sch, out, result)
+
+		// Only a request that failed before any output can be sent to the next profile
+		if err == nil || result.started() || isCancelled(ctx) || !canFallBack(err) || len(app.fallbacks) == 0 {
+			return app.finish(ctx, out, result, err)
+		}
+
+		if ferr := app.nextProvider(err); ferr != nil {
+			return app.finish(ctx, out, result, ferr)
+		}
+	}
+}
+
+// ask sends the request to the current provider, with the timeouts of its profile, and
+// validates the answer when a schema is given.
+func (app *App) ask(ctx context.Context, request provider.GenerateRequest, sch *schema.Schema, out output, result *Result) error {
+
+	timeout, firstTokenTimeout := app.timeouts()
+
+	ctx, cancel := withRequestTimeout(ctx, timeout)
 	defer cancel()
 
-	throbber := throbber.NewThrobber().
-		WithMessage("Generating response...").
-		WithThrob(throbber.ThrobByName("binary")).
-		Start()
+	// Checked for every provider, a fallback profile may use another one
+	if sch != nil && !provider.EnforcesSchema(app.Provider) {
+		request.System += schemaInstruction(sch)
+	}
 
-	mdRenderer, err := markdown.NewMarkdownRenderer("dark")
-	if err != nil {
-		return fmt.Errorf("failed to initialize markdown renderer: %w", err)
+	err := app.generate(ctx, request, out, result, firstTokenTimeout)
+
+	if err == nil && sch != nil {
+		err = app.validateAnswer(ctx, sch, request, out, result, firstTokenTimeout)
+	}
+
+	return err
+}
+
+// generate sends the request to the provider of the profile, showing a throbber until
+// it answers, see stream.
+func (app *App) generate(ctx context.Context, request provider.GenerateRequest, out output, result *Result, firstTokenTimeout time.Duration) error {
+
+	// JSON output is meant for programs, which have no use for the throbber
+	spinner := throbber.NewThrobber()
+	if mode := app.outputMode(); mode != OUTPUT_JSON && mode != OUTPUT_NDJSON {
+		spinner = app.UI.StartThrobber("Generating response...", throbber.ThrobByName("binary"))
 	}
 
 	// Don't defer stop - we'll stop it explicitly to ensure proper sequence
 
-	responseChan, errorChan := app.Provider.Generate(ctx, *request)
+	return app.stream(ctx, app.Provider, request, out, result, firstTokenTimeout, spinner)
+}
+
+// stream sends the request to p and passes every chunk of the response to out. It
+// returns nil once the response is complete, the provider error when the request failed,
+// or the cause when the request was cancelled or timed out. The spinner is stopped as
+// soon as the provider answers.
+func (app *App) stream(ctx context.Context, p provider.IProvider, request provider.GenerateRequest, out output, result *Result, firstTokenTimeout time.Duration, spinner *throbber.Throbber) error {
+
+	ctx, cancelIdle := context.WithCancelCause(ctx)
+	defer cancelIdle(nil)
+
+	// Fires when no chunk arrived within firstTokenTimeout, nil channel when disabled
+	var idleC <-chan time.Time
+	var idleTimer *time.Timer
+	received := false
+
+	if firstTokenTimeout > 0 {
+		idleTimer = time.NewTimer(firstTokenTimeout)
+		defer idleTimer.Stop()
+		idleC = idleTimer.C
+	}
+
+	responseChan, errorChan := p.Generate(ctx, request)
 
 	for {
 		select {
 		case response, ok := <-responseChan:
+			if spinner.IsRunning() {
+				spinner.Stop()
+			}
+
 			if !ok {
-				if throbber.IsRunning() {
-					throbber.Stop()
+				// The error channel is closed first, so this doesn't block
+				if err, ok := <-errorChan; ok {
+					if ctx.Err() != nil {
+						return context.Cause(ctx)
+					}
+					return err
 				}
+
 				return nil // Channel closed
 			}
 
-			if throbber.IsRunning() {
-				throbber.Stop()
+			received = true
+			if idleTimer != nil {
+				idleTimer.Reset(firstTokenTimeout)
 			}
 
-			if app.Flags.DebugStream {
-				utils.Dump(response)
-			} else {
-
-				rendered := response.Response
+			result.add(response, app.Now())
 
-				if app.Flags.Color {
-					rendered, err = mdRenderer.Render(response.Response, response.Done)
-					if err != nil {
-						return fmt.Errorf("error rendering markdown: %w", err)
-					}
-				}
-
-				if rendered != "" {
-					fmt.Print(rendered)
-				}
-
-				if response.Done && app.Flags.Color {
-					// Flush any remaining content
-					remaining, _ := mdRenderer.Render("", true)
-					remaining = strings.Trim(remaining, "\n")
-					if remaining != "" {
-						fmt.Print(remaining)
-					}
-				}
+			if app.Flags.DebugStream {
+				app.UI.Dump(response)
+			} else if err := out.Write(response); err != nil {
+				return err
 			}
 
 			if response.Done {
-				// Markdown adds a trailing newline
-				if !app.Flags.Color {
-					fmt.Println()
-				}
-
 				return nil
 			}
 
 		case err, ok := <-errorChan:
+			if spinner.IsRunning() {
+				spinner.Stop()
+			}
+
 			if !ok {
-				if throbber.IsRunning() {
-					throbber.Stop()
-				}
 				return nil // Channel closed
 			}
 
-			if throbber.IsRunning() {
-				throbber.Stop()
+			// The provider noticed the cancellation or timeout before we did
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+
+			return err
+
+		case <-idleC:
+			if spinner.IsRunning() {
+				spinner.Stop()
+			}
+
+			if received {
+				cancelIdle(errIdleTimeout(firstTokenTimeout))
+			} else {
+				cancelIdle(errFirstTokenTimeout(firstTokenTimeout))
 			}
 
-			return err // Return the error
+			return context.Cause(ctx)
 
 		case <-ctx.Done():
-			if throbber.IsRunning() {
-				throbber.Stop()
+			if spinner.IsRunning() {
+				spinner.Stop()
 			}
-			fmt.Println("Operation timed out")
-			return ctx.Err()
+
+			return context.Cause(ctx)
 		}
 	}
 
 }
+
+// finish completes the output. On failure, the partial response that was received
+// before the request failed, was cancelled or timed out is flushed.
+func (app *App) finish(ctx context.Context, out output, result *Result, err error) error {
+	if err == nil {
+		return app.complete(out, result)
+	}
+
+	result.finish(err, app.Now())
+
+	if cerr := out.Close(result); cerr != nil {
+		return cerr
+	}
+
+	if isCancelled(ctx) {
+		app.UI.Println("[cancelled]")
+	}
+
+	app.printStats(result)
+	app.recordUsage(result)
+	app.logResult(result)
+
+	return err
+}
+
+// complete finishes the output of a successful response.
+func (app *App) complete(out output, result *Result) error {
+	result.finish(nil, app.Now())
+
+	if err := out.Close(result); err != nil {
+		return err
+	}
+
+	app.printStats(result)
+	app.recordUsage(result)
+	app.logResult(result)
+
+	if app.Flags.Copy {
+		app.copyAnswer(result.Response)
+	}
+
+	return nil
+}
+
+// copyAnswer copies the suggested command from the answer to the clipboard.
+func (app *App) copyAnswer(answer string) {
+	err := desktop.WriteClipboard(markdown.ExtractCode(answer))
+
+	if err != nil {
+		app.UI.Printf("Unable to copy to clipboard: %v\n", err)
+	}
+}
+
+// printStats writes the usage statistics to stderr when --stats is set.
+func (app *App) printStats(result *Result) {
+	if app.Flags.Stats {
+		app.UI.Println(formatStats(result))
+	}
+}
@@ -0,0 +1,495 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+const testConfig = `{
+  "profile": "default",
+  "profiles": {
+    "default": {
+      "provider": "mock",
+      "settings": { "response": "Use **ls -la**.", "chunk_size": 4 }
+    },
+    "echo": {
+      "provider": "mock"
+    },
+    "typo": {
+      "provider": "mokc"
+    },
+    "rate-limited": {
+      "provider": "mock",
+      "settings": { "error_at": 1, "error": "rate_limit", "error_message": "slow down" }
+    }
+  }
+}`
+
+// testRun is the outcome of a single qai invocation.
+type testRun struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+// testApp is qai with a temporary home directory, a fixed clock and no access to the
+// real environment or system config, see newTestApp.
+type testApp struct {
+	*App
+	stdout, stderr bytes.Buffer
+}
+
+// newTestApp returns qai set up for a test. The env entries are added to the environment.
+func newTestApp(home string, stdin string, env map[string]string) *testApp {
+	environ := []string{"HOME=" + home}
+	for k, v := range env {
+		environ = append(environ, k+"="+v)
+	}
+
+	// The clock is shared by the requests of --compare
+	var mu sync.Mutex
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(10 * time.Millisecond)
+		return now
+	}
+
+	a := &testApp{}
+
+	a.App = NewApp().
+		WithIO(strings.NewReader(stdin), &a.stdout, &a.stderr).
+		WithEnv(environ).
+		WithWorkDir(home).
+		WithSystemConfigDir(filepath.Join(home, "etc")).
+		WithClock(clock)
+
+	return a
+}
+
+// run runs qai with the given arguments.
+func (a *testApp) run(args ...string) testRun {
+	code := a.Main(append([]string{"qai"}, args...))
+
+	return testRun{code: code, stdout: a.stdout.String(), stderr: a.stderr.String()}
+}
+
+// runApp runs qai set up by newTestApp.
+func runApp(t *testing.T, home string, stdin string, env map[string]string, args ...string) testRun {
+	t.Helper()
+
+	return newTestApp(home, stdin, env).run(args...)
+}
+
+// newHome returns a temporary home directory with config installed as the user config.
+func newHome(t *testing.T, config string) string {
+	t.Helper()
+
+	home := t.TempDir()
+	file := homeConfigFile(home)
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return home
+}
+
+// homeConfigFile returns the path of the user config in home.
+func homeConfigFile(home string) string {
+	return filepath.Join(home, ".config", "qai", CONFIG_FILENAME)
+}
+
+func TestFirstRunCreatesConfig(t *testing.T) {
+	home := t.TempDir()
+
+	run := runApp(t, home, "", nil)
+
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+	if !strings.Contains(run.stderr, "creating a new one") {
+		t.Fatalf("expected config creation message, got %q", run.stderr)
+	}
+
+	config, err := LoadConfig(homeConfigFile(home))
+	if err != nil {
+		t.Fatalf("config was not created: %v", err)
+	}
+	if _, err := config.GetProfile(config.Profile); err != nil {
+		t.Fatalf("created config has no default profile: %v", err)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	home := t.TempDir()
+
+	run := runApp(t, home, "", nil, "--version")
+
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+	if !strings.HasPrefix(run.stdout, APP_NAME+" "+APP_VERSION) {
+		t.Fatalf("unexpected version output %q", run.stdout)
+	}
+	if !strings.Contains(run.stdout, homeConfigFile(home)) {
+		t.Fatalf("version output does not show the config file: %q", run.stdout)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config")); !os.IsNotExist(err) {
+		t.Fatalf("--version must not create a config file")
+	}
+}
+
+func TestMissingPrompt(t *testing.T) {
+	run := runApp(t, newHome(t, testConfig), "", nil)
+
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+	if run.stdout != "" {
+		t.Fatalf("expected no answer, got %q", run.stdout)
+	}
+	if !strings.Contains(run.stderr, "No prompt provided") {
+		t.Fatalf("expected missing prompt message, got %q", run.stderr)
+	}
+}
+
+func TestInvalidFlag(t *testing.T) {
+	run := runApp(t, newHome(t, testConfig), "", nil, "--no-such-flag", "hello")
+
+	if run.code != EXIT_USAGE {
+		t.Fatalf("expected exit code %d, got %d", EXIT_USAGE, run.code)
+	}
+	if strings.Count(run.stderr, "no-such-flag") != 1 {
+		t.Fatalf("expected the error to be reported once, got %q", run.stderr)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	run := runApp(t, newHome(t, testConfig), "", nil, "-h")
+
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+	if !strings.Contains(run.stderr, "Usage:") {
+		t.Fatalf("expected usage, got %q", run.stderr)
+	}
+}
+
+func TestRawOutput(t *testing.T) {
+	run := runApp(t, newHome(t, testConfig), "", nil, "list", "files")
+
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+	if run.stdout != "Use **ls -la**.\n" {
+		t.Fatalf("unexpected answer %q", run.stdout)
+	}
+}
+
+func TestProfileSelection(t *testing.T) {
+	home := newHome(t, testConfig)
+
+	run := runApp(t, home, "", nil, "--profile", "echo", "hello", "world")
+
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+	if run.stdout != "hello world\n" {
+		t.Fatalf("expected the echo profile to answer, got %q", run.stdout)
+	}
+
+	// The profile can also be selected from the environment
+	run = runApp(t, home, "", map[string]string{"PROFILE": "echo"}, "hi")
+
+	if run.stdout != "hi\n" {
+		t.Fatalf("expected the echo profile to answer, got %q", run.stdout)
+	}
+
+	run = runApp(t, home, "", nil, "--profile", "missing", "hello")
+
+	if run.code != EXIT_ERROR {
+		t.Fatalf("expected exit code %d, got %d", EXIT_ERROR, run.code)
+	}
+	if !strings.Contains(run.stderr, `"missing"`) {
+		t.Fatalf("expected the profile in the error, got %q", run.stderr)
+	}
+}
+
+func TestUnknownProvider(t *testing.T) {
+	run := runApp(t, newHome(t, testConfig), "", nil, "--profile", "typo", "hello")
+
+	if run.code != EXIT_ERROR {
+		t.Fatalf("expected exit code %d, got %d", EXIT_ERROR, run.code)
+	}
+	if !strings.Contains(run.stderr, `unknown provider "mokc"`) {
+		t.Fatalf("expected the unknown provider in the error, got %q", run.stderr)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	run := runApp(t, newHome(t, testConfig), "", nil, "config", "validate")
+
+	if run.code != EXIT_ERROR {
+		t.Fatalf("expected exit code %d, got %d", EXIT_ERROR, run.code)
+	}
+	if !strings.Contains(run.stdout, `config.json:12:19: profiles.typo.provider:`) {
+		t.Fatalf("expected the unknown provider to be reported, got %q", run.stdout)
+	}
+
+	run = runApp(t, newHome(t, testConfig), "", nil, "config")
+
+	if run.code != EXIT_USAGE || !strings.Contains(run.stderr, "validate") {
+		t.Fatalf("expected the config commands, got %d %q", run.code, run.stderr)
+	}
+}
+
+func TestPromptFromStdin(t *testing.T) {
+	run := runApp(t, newHome(t, testConfig), "  piped prompt\n", nil, "--profile", "echo", "-")
+
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+	if run.stdout != "piped prompt\n" {
+		t.Fatalf("unexpected answer %q", run.stdout)
+	}
+}
+
+func TestProviderError(t *testing.T) {
+	run := runApp(t, newHome(t, testConfig), "", nil, "--profile", "rate-limited", "hello")
+
+	if run.code != EXIT_RATE_LIMIT {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", EXIT_RATE_LIMIT, run.code, run.stderr)
+	}
+	if !strings.Contains(run.stderr, "slow down") {
+		t.Fatalf("expected the provider error on stderr, got %q", run.stderr)
+	}
+	// The partial answer ends with a newline
+	if run.stdout != "hello\n" {
+		t.Fatalf("expected the partial answer, got %q", run.stdout)
+	}
+
+	home := newHome(t, `{
+  "profile": "offline",
+  "profiles": {
+    "offline": { "provider": "mock", "settings": { "error_at": 0, "error": "connection" } }
+  }
+}`)
+
+	run = runApp(t, home, "", nil, "hello")
+
+	// Nothing was answered, not even an empty line
+	if run.code != EXIT_CONNECTION || run.stdout != "" {
+		t.Fatalf("expected an error without output, got %d %q", run.code, run.stdout)
+	}
+}
+
+func TestMarkdownOutput(t *testing.T) {
+	run := runApp(t, newHome(t, testConfig), "", nil, "--color", "always", "--style", "dark", "hello")
+
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+	if !strings.Contains(run.stdout, "\x1b[") {
+		t.Fatalf("expected colored output, got %q", run.stdout)
+	}
+	if strings.Contains(run.stdout, "**") || !strings.Contains(run.stdout, "ls -la") {
+		t.Fatalf("expected rendered markdown, got %q", run.stdout)
+	}
+}
+
+func TestNDJSONOutput(t *testing.T) {
+	run := runApp(t, newHome(t, testConfig), "", nil, "--output", "ndjson", "hello")
+
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+
+	var text strings.Builder
+	var types []string
+
+	scanner := bufio.NewScanner(strings.NewReader(run.stdout))
+	for scanner.Scan() {
+		var event map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+
+		types = append(types, event["type"].(string))
+
+		if event["type"] == "chunk" {
+			text.WriteString(event["text"].(string))
+		}
+	}
+
+	// "Use **ls -la**." in chunks of 4 characters
+	if len(types) != 5 || types[len(types)-1] != "done" {
+		t.Fatalf("unexpected events %q", types)
+	}
+	if text.String() != "Use **ls -la**." {
+		t.Fatalf("unexpected streamed text %q", text.String())
+	}
+}
+
+func TestSystemPromptFromConfig(t *testing.T) {
+	app := NewApp()
+	app.Config = NewConfig()
+	app.Config.System = "Answer in French."
+
+	system, err := app.getSystemPrompt()
+	if err != nil || system != "Answer in French." {
+		t.Fatalf("expected the system prompt of the config, got %q (%v)", system, err)
+	}
+
+	app.Flags.System = "Be brief."
+
+	system, err = app.getSystemPrompt()
+	if err != nil || system != "Be brief." {
+		t.Fatalf("expected -system to override the config, got %q (%v)", system, err)
+	}
+}
+
+func TestMarkdownOutputWithoutColors(t *testing.T) {
+	for _, run := range []testRun{
+		runApp(t, newHome(t, testConfig), "", nil, "--color", "never", "--output", "markdown", "--style", "dark", "hello"),
+		runApp(t, newHome(t, testConfig), "", map[string]string{"NO_COLOR": "1"}, "--output", "markdown", "hello"),
+	} {
+		if run.code != EXIT_OK {
+			t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+		}
+		if strings.Contains(run.stdout, "\x1b[") || !strings.Contains(run.stdout, "ls -la") {
+			t.Fatalf("expected markdown without colors, got %q", run.stdout)
+		}
+	}
+}
+
+func TestColorFlag(t *testing.T) {
+	tests := []struct {
+		args   []string
+		env    map[string]string
+		color  string
+		prompt string
+	}{
+		{[]string{"hello"}, nil, "auto", "hello"},
+		{[]string{"-color", "hello"}, nil, "always", "hello"},
+		{[]string{"-color", "never", "hello"}, nil, "never", "hello"},
+		{[]string{"--color=false", "hello"}, nil, "never", "hello"},
+		{[]string{"-profile", "-color", "hello"}, nil, "auto", "hello"},
+		{[]string{"hello", "-color", "never"}, nil, "auto", "hello -color never"},
+		{[]string{"hello"}, map[string]string{"COLOR": "1"}, "always", "hello"},
+		{[]string{"-color", "never", "hello"}, map[string]string{"COLOR": "1"}, "never", "hello"},
+	}
+
+	for _, tt := range tests {
+		lookupEnv := func(key string) (string, bool) {
+			v, ok := tt.env[key]
+			return v, ok
+		}
+
+		flags, err := parseFlags(tt.args, false, provider.NewFlagValues("", ""), lookupEnv, io.Discard)
+		if err != nil {
+			t.Fatalf("parseFlags(%q) failed: %v", tt.args, err)
+		}
+		if flags.Color != tt.color || flags.Prompt != tt.prompt {
+			t.Fatalf("parseFlags(%q) with env %v = %q, %q, want %q, %q", tt.args, tt.env, flags.Color, flags.Prompt, tt.color, tt.prompt)
+		}
+	}
+
+	run := runApp(t, newHome(t, testConfig), "", nil, "-color", "-style", "dark", "hello")
+
+	if run.code != EXIT_OK || !strings.Contains(run.stdout, "\x1b[") {
+		t.Fatalf("expected colored output, got %d %q", run.code, run.stdout)
+	}
+
+	run = runApp(t, newHome(t, testConfig), "", nil, "-color=sometimes", "hello")
+
+	if run.code != EXIT_USAGE || !strings.Contains(run.stderr, `invalid color mode "sometimes"`) {
+		t.Fatalf("expected an invalid color mode, got %d %q", run.code, run.stderr)
+	}
+}
+
+func TestFlagsFromEnv(t *testing.T) {
+	home := newHome(t, testConfig)
+
+	// Variables of other programs
+	run := runApp(t, home, "", map[string]string{"TIMEOUT": "abc", "OUTPUT": "xml", "RECORD": home, "LOG_FILE": "/nonexistent/qai.log"}, "hello")
+
+	if run.code != EXIT_OK || run.stdout != "Use **ls -la**.\n" {
+		t.Fatalf("expected the answer, got %d %q, stderr: %s", run.code, run.stdout, run.stderr)
+	}
+	if strings.Contains(run.stderr, "Recording") {
+		t.Fatalf("expected RECORD to be ignored, got %q", run.stderr)
+	}
+
+	run = runApp(t, home, "", map[string]string{"QAI_OUTPUT": "json", "QAI_TIMEOUT": "1m"}, "hello")
+
+	if run.code != EXIT_OK || !strings.HasPrefix(run.stdout, "{") {
+		t.Fatalf("expected QAI_OUTPUT to select JSON, got %d %q", run.code, run.stdout)
+	}
+
+	run = runApp(t, home, "", map[string]string{"QAI_TIMEOUT": "abc"}, "hello")
+
+	if run.code != EXIT_USAGE {
+		t.Fatalf("expected an invalid QAI_TIMEOUT to be a usage error, got %d %q", run.code, run.stderr)
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	home := newHome(t, testConfig)
+	file := filepath.Join(home, "qai.log")
+
+	app := newTestApp(home, "", nil)
+
+	if run := app.run("-log-file", file, "-log-level", "info", "hello"); run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+
+	if app.logFile != nil {
+		t.Fatal("expected the log file to be closed")
+	}
+
+	if data, err := os.ReadFile(file); err != nil || !strings.Contains(string(data), "request finished") {
+		t.Fatalf("expected the request to be logged, got %q (%v)", data, err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	// The server is never contacted
+	home := newHome(t, `{
+  "profile": "local",
+  "profiles": {
+    "local": { "provider": "ollama", "settings": { "model": "llama3.2", "url": "http://127.0.0.1:1" } },
+    "other": { "provider": "ollama", "settings": { "model": "qwen3", "url": "http://127.0.0.1:1" } }
+  }
+}`)
+	cassette := filepath.Join("..", "providers", "ollama", "testdata", "generate.json")
+
+	run := runApp(t, home, "", nil, "-replay", cassette, "list", "files")
+
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+	if run.stdout != "Use `ls -la`.\n" {
+		t.Fatalf("unexpected answer %q", run.stdout)
+	}
+
+	run = runApp(t, home, "", nil, "-replay", cassette, "-profile", "other", "list", "files")
+
+	if run.code == EXIT_OK || !strings.Contains(run.stderr, `expected model "llama3.2"`) {
+		t.Fatalf("expected a model mismatch, got %d %q", run.code, run.stderr)
+	}
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// command is a subcommand such as "qai usage". Subcommands share the global flags,
+// which have to be given before the command name.
+type command struct {
+	name        string
+	description string
+	run         func(app *App, args []string) error
+	// The command reads the config file itself, so it also works on a broken config
+	noConfig bool
+	// Commands grouped under this one, such as "qai config validate"
+	subcommands []command
+}
+
+var commands = []command{
+	{
+		name:        "usage",
+		description: "Summarize the requests recorded in the usage ledger",
+		run:         (*App).runUsage,
+	},
+	{
+		name:        "profiles",
+		description: "List the profiles with their provider and model, the default is marked with *",
+		run:         (*App).runProfiles,
+	},
+	{
+		name:        "config",
+		description: "Manage the config file",
+		subcommands: []command{
+			{
+				name:        "validate",
+				description: "Check the config file for unknown keys, providers and invalid values",
+				run:         (*App).runConfigValidate,
+				noConfig:    true,
+			},
+			{
+				name:        "get",
+				description: "Print the value of a key, such as profiles.work.settings.model",
+				run:         (*App).runConfigGet,
+				noConfig:    true,
+			},
+			{
+				name:        "set",
+				description: "Set the value of a key, such as: set profile work",
+				run:         (*App).runConfigSet,
+				noConfig:    true,
+			},
+			{
+				name:        "edit",
+				description: "Open the config file in $EDITOR and validate the changes",
+				run:         (*App).runConfigEdit,
+				noConfig:    true,
+			},
+			{
+				name:        "show",
+				description: "Print the merged config, or with --effective the settings of the active profile",
+				run:         (*App).runConfigShow,
+			},
+			{
+				name:        "sources",
+				description: "List the config layers and which of them apply",
+				run:         (*App).runConfigSources,
+				noConfig:    true,
+			},
+			{
+				name:        "schema",
+				description: "Print the JSON Schema of the config file",
+				run:         (*App).runConfigSchema,
+				noConfig:    true,
+			},
+		},
+	},
+}
+
+// findCommand returns the command named by the first positional argument and its arguments.
+// A command name followed by anything other than options is treated as a prompt, so
+// "qai usage of awk" still asks a question. The name of a subcommand may be followed by
+// positional arguments.
+func findCommand(args []string) (*command, []string) {
+	return findIn(commands, args, true)
+}
+
+func findIn(cmds []command, args []string, strict bool) (*command, []string) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	for i := range cmds {
+		c := &cmds[i]
+
+		if args[0] != c.name {
+			continue
+		}
+
+		rest := args[1:]
+		positional := len(rest) > 0 && !strings.HasPrefix(rest[0], "-")
+
+		if len(c.subcommands) > 0 && positional {
+			return findIn(c.subcommands, rest, false)
+		}
+
+		if strict && positional {
+			return nil, nil
+		}
+
+		return c, rest
+	}
+
+	return nil, nil
+}
+
+// printCommands writes the subcommands of c with their descriptions.
+func printCommands(w io.Writer, c *command) {
+	fmt.Fprintf(w, "Usage: %s [options] %s <command> [arguments]\n\nCommands:\n", APP_NAME, c.name)
+
+	for _, sub := range c.subcommands {
+		fmt.Fprintf(w, "  %-10s %s\n", sub.name, sub.description)
+	}
+}
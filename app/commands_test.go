@@ -0,0 +1,49 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		name string
+		rest []string
+	}{
+		{nil, "", nil},
+		{[]string{"usage"}, "usage", []string{}},
+		{[]string{"usage", "--by", "model"}, "usage", []string{"--by", "model"}},
+		{[]string{"usage", "of", "awk"}, "", nil},
+		{[]string{"how", "to", "use", "usage"}, "", nil},
+		{[]string{"config"}, "config", []string{}},
+		{[]string{"config", "validate"}, "validate", []string{}},
+		{[]string{"config", "validate", "extra"}, "validate", []string{"extra"}},
+		{[]string{"config", "is", "broken"}, "", nil},
+	}
+
+	for _, tt := range tests {
+		cmd, rest := findCommand(tt.args)
+
+		name := ""
+		if cmd != nil {
+			name = cmd.name
+		}
+
+		if name != tt.name || !reflect.DeepEqual(rest, tt.rest) {
+			t.Fatalf("findCommand(%q) = %q, %q, want %q, %q", tt.args, name, rest, tt.name, tt.rest)
+		}
+	}
+}
+
+func TestUsageInvalidFlag(t *testing.T) {
+	run := runApp(t, newHome(t, testConfig), "", nil, "usage", "--bogus")
+
+	if run.code != EXIT_USAGE {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", EXIT_USAGE, run.code, run.stderr)
+	}
+	if n := strings.Count(run.stderr, "flag provided but not defined"); n != 1 {
+		t.Fatalf("expected the error once, got %q", run.stderr)
+	}
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mcnull/qai/shared/provider"
+	"github.com/mcnull/qai/shared/throbber"
+)
+
+// comparison is one of the profiles asked with --compare.
+type comparison struct {
+	name     string
+	profile  *Profile
+	provider provider.IProvider
+	result   *Result
+	out      output
+}
+
+// initCompare creates the provider of every profile given with --compare. The profiles
+// are asked as they are, without their fallback profiles.
+func (app *App) initCompare() error {
+	if app.Flags.Schema != "" || app.Flags.Copy {
+		return app.usageErrorf("-compare can't be combined with -schema or -copy")
+	}
+
+	var names []string
+	for _, name := range strings.Split(app.Flags.Compare, ",") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) < 2 {
+		return app.usageErrorf("-compare needs at least two profiles, such as -compare local,copilot")
+	}
+
+	for _, name := range names {
+		p, profile, err := app.newProvider(name)
+		if err != nil {
+			return fmt.Errorf("error in profile %q: %w", name, err)
+		}
+
+		app.comparisons = append(app.comparisons, &comparison{name: name, profile: profile, provider: p})
+	}
+
+	return nil
+}
+
+// runCompare sends the prompt to every profile at once. Once all of them are done, the
+// answers are written one after another, each with its statistics.
+func (app *App) runCompare(system string) error {
+	mode := app.outputMode()
+
+	request := provider.GenerateRequest{
+		System: system,
+		Prompt: app.Flags.Prompt,
+		// Streaming measures the time to the first token
+		Stream: true,
+	}
+
+	ctx, stop := app.notifyContext(context.Background())
+	defer stop()
+
+	// The outputs are created before any request is sent, so that an error leaves
+	// nothing running
+	for _, c := range app.comparisons {
+		out, err := app.newOutput(mode, app.Stdout)
+		if err != nil {
+			return err
+		}
+
+		// The events of all profiles are written to the same stream
+		if o, ok := out.(*ndjsonOutput); ok {
+			o.profile = c.name
+		}
+
+		// Nothing is written until all answers are complete
+		c.out = &bufferedOutput{output: out}
+		c.result = newResult(c.provider.GetName(), c.name, "", app.Now())
+	}
+
+	spinner := throbber.NewThrobber()
+	if mode != OUTPUT_JSON && mode != OUTPUT_NDJSON {
+		spinner = app.UI.StartThrobber(fmt.Sprintf("Asking %d profiles...", len(app.comparisons)), throbber.ThrobByName("binary"))
+	}
+
+	var wg sync.WaitGroup
+
+	for _, c := range app.comparisons {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			timeout, firstTokenTimeout := app.profileTimeouts(c.profile)
+
+			ctx, cancel := withRequestTimeout(ctx, timeout)
+			defer cancel()
+
+			err := app.stream(ctx, c.provider, request, c.out, c.result, firstTokenTimeout, throbber.NewThrobber())
+			c.result.finish(err, app.Now())
+		}()
+	}
+
+	wg.Wait()
+
+	if spinner.IsRunning() {
+		spinner.Stop()
+	}
+
+	if isCancelled(ctx) {
+		app.UI.Println("[cancelled]")
+	}
+
+	failed := 0
+	results := make([]*Result, 0, len(app.comparisons))
+
+	for _, c := range app.comparisons {
+		if c.result.Error != nil {
+			failed++
+		}
+
+		results = append(results, c.result)
+		app.recordUsage(c.result)
+		app.logResult(c.result)
+	}
+
+	if err := app.writeComparison(mode, results); err != nil {
+		return err
+	}
+
+	if isCancelled(ctx) {
+		return ErrCancelled
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d profiles failed", failed, len(results))
+	}
+
+	return nil
+}
+
+// writeComparison writes the results of --compare. JSON output is a single array, the
+// other formats write every answer below a header, followed by its statistics.
+func (app *App) writeComparison(mode string, results []*Result) error {
+	if mode == OUTPUT_JSON {
+		return app.writeJSON(results)
+	}
+
+	for i, c := range app.comparisons {
+		result := results[i]
+
+		if mode == OUTPUT_NDJSON {
+			if err := c.out.Close(result); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if i > 0 {
+			fmt.Fprintln(app.Stdout)
+		}
+
+		label := result.Provider
+		if result.Model != "" {
+			label += ", " + result.Model
+		}
+
+		fmt.Fprintf(app.Stdout, "==> %s (%s) <==\n", c.name, label)
+
+		if result.Error != nil {
+			fmt.Fprintf(app.Stdout, "Error: %s\n", result.Error.Message)
+		} else if err := c.out.Close(result); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(app.Stdout, formatStats(result))
+	}
+
+	return nil
+}
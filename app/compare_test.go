@@ -0,0 +1,90 @@
+package app
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testCompareConfig = `{
+  "profile": "short",
+  "profiles": {
+    "short": { "provider": "mock", "settings": { "model": "small", "response": "ls", "delay": "20ms" } },
+    "long": { "provider": "mock", "settings": { "model": "large", "response": "ls -la", "chunk_size": 2 } },
+    "offline": { "provider": "mock", "settings": { "error_at": 0, "error": "connection", "error_message": "no network" } }
+  }
+}`
+
+func TestCompare(t *testing.T) {
+	home := newHome(t, testCompareConfig)
+
+	run := runApp(t, home, "", nil, "-output", "raw", "-compare", "short, long", "list files")
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+
+	// The answers are written in the order of the profiles, not in the order they complete
+	sections := strings.Split(run.stdout, "\n\n")
+	if len(sections) != 2 {
+		t.Fatalf("expected two answers, got %q", run.stdout)
+	}
+
+	for i, want := range []string{"==> short (mock, small) <==\nls\ntokens: ", "==> long (mock, large) <==\nls -la\ntokens: "} {
+		if !strings.HasPrefix(sections[i], want) {
+			t.Fatalf("expected %q to start with %q", sections[i], want)
+		}
+	}
+
+	run = runApp(t, home, "", nil, "-output", "json", "-compare", "short,long,offline", "list files")
+	if run.code != EXIT_ERROR || !strings.Contains(run.stderr, "1 of 3 profiles failed") {
+		t.Fatalf("expected the failed profile to be reported, got %d %q", run.code, run.stderr)
+	}
+
+	var results []Result
+	if err := json.Unmarshal([]byte(run.stdout), &results); err != nil {
+		t.Fatalf("invalid JSON %q: %v", run.stdout, err)
+	}
+
+	if len(results) != 3 || results[1].Profile != "long" || results[1].Response != "ls -la" || results[1].Usage == nil {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if results[2].Error == nil || results[2].Error.Class != "connection" {
+		t.Fatalf("expected a connection error for the offline profile, got %+v", results[2])
+	}
+
+	// Every ndjson event names its profile
+	run = runApp(t, home, "", nil, "-output", "ndjson", "-compare", "short,long", "list files")
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+
+	var profiles []string
+	for _, line := range strings.Split(strings.TrimSpace(run.stdout), "\n") {
+		var event struct {
+			Type    string `json:"type"`
+			Profile string `json:"profile"`
+			Text    string `json:"text"`
+		}
+
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid JSON %q: %v", line, err)
+		}
+
+		profiles = append(profiles, event.Type+" "+event.Profile+" "+event.Text)
+	}
+
+	if want := []string{"chunk short ls", "done short ", "chunk long ls -la", "done long "}; !slices.Equal(profiles, want) {
+		t.Fatalf("expected the events %q, got %q", want, profiles)
+	}
+
+	run = runApp(t, home, "", nil, "-compare", "short", "list files")
+	if run.code != EXIT_USAGE {
+		t.Fatalf("expected a usage error for a single profile, got %d %q", run.code, run.stderr)
+	}
+
+	run = runApp(t, home, "", nil, "-compare", "short,nope", "list files")
+	if run.code != EXIT_ERROR || !strings.Contains(run.stderr, `profile "nope" is not defined`) {
+		t.Fatalf("expected an unknown profile error, got %d %q", run.code, run.stderr)
+	}
+}
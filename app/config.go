@@ -1,45 +1,83 @@
 package app
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+
 	"github.com/mcnull/qai/providers/github"
+	"github.com/mcnull/qai/providers/mock"
 	"github.com/mcnull/qai/providers/ollama"
+	"github.com/mcnull/qai/shared/configfile"
 	"github.com/mcnull/qai/shared/jsonmap"
+	"github.com/mcnull/qai/shared/ledger"
 	"github.com/mcnull/qai/shared/provider"
+	"github.com/mcnull/qai/shared/ui"
+	"github.com/mcnull/qai/shared/utils"
 )
 
 type Config struct {
+	// JSON Schema reference for editors, see "qai config schema"
+	Schema string `json:"$schema,omitempty"`
+	// The version of the config format, older files are upgraded on load
+	Version   int                `json:"version"`
 	Profile   string             `json:"profile"`
 	System    string             `json:"system"`
 	Providers ProvidersConfig    `json:"providers"`
 	Profiles  map[string]Profile `json:"profiles"`
+	Usage     *UsageConfig       `json:"usage,omitempty"`
+
+	// The file the config was loaded from, to keep its comments when saving
+	file *configfile.File
 }
 
 type ProvidersConfig struct {
 	Ollama provider.IConfig `json:"ollama"`
 	GitHub provider.IConfig `json:"github"`
+	Mock   provider.IConfig `json:"mock"`
 }
 
 type Profile struct {
-	Provider string          `json:"provider"`
-	Settings jsonmap.JsonMap `json:"settings,omitempty"`
+	// The name of a profile this one is based on. Settings are merged, other values
+	// of this profile override those of the base.
+	Extends  string `json:"extends,omitempty"`
+	Provider string `json:"provider"`
+	// Profiles that are tried in order when the provider of this profile can't be
+	// reached, rejects the credentials or times out before it answers
+	Fallback          []string        `json:"fallback,omitempty"`
+	Settings          jsonmap.JsonMap `json:"settings,omitempty"`
+	Timeout           *utils.Duration `json:"timeout,omitempty"`
+	FirstTokenTimeout *utils.Duration `json:"first_token_timeout,omitempty"`
+}
+
+// UsageConfig controls the local usage ledger.
+type UsageConfig struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// The prompt text is only stored in the ledger when enabled explicitly
+	StorePrompts bool `json:"store_prompts,omitempty"`
+	// Prices per model name, used to estimate costs in the usage report
+	Prices map[string]ledger.Price `json:"prices,omitempty"`
 }
 
 func NewConfig() *Config {
 
 	ollamaConfig := ollama.NewConfig()
 	githubConfig := github.NewConfig()
+	mockConfig := mock.NewConfig()
 
 	return &Config{
+		Version: CONFIG_VERSION,
 		Profile: DEFAULT_PROFILE,
 		System:  DEFAULT_SYSTEM_PROMPT,
 		Providers: ProvidersConfig{
 			Ollama: ollamaConfig,
 			GitHub: githubConfig,
+			Mock:   mockConfig,
 		},
 		Profiles: map[string]Profile{
 			DEFAULT_PROFILE: {
@@ -49,38 +87,171 @@ func NewConfig() *Config {
 	}
 }
 
+// LoadConfig reads the config file at filepath, as JSON with comments, YAML or TOML
+// depending on its extension.
 func LoadConfig(filepath string) (*Config, error) {
 
-	// unmarshal the JSON file into a Config struct
-
 	config := NewConfig()
 
-	file, err := os.Open(filepath)
+	file, err := configfile.Read(filepath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(config)
+	err = file.Decode(config)
 	if err != nil {
 		return nil, err
 	}
 
+	config.file = file
+
 	return config, nil
 }
 
+// ProfileNotFoundError is returned by Config.GetProfile for a profile that is not defined.
+type ProfileNotFoundError struct {
+	Name      string
+	Available []string
+}
+
+func (e *ProfileNotFoundError) Error() string {
+	if len(e.Available) == 0 {
+		return fmt.Sprintf("profile %q is not defined, the config has no profiles", e.Name)
+	}
+	return fmt.Sprintf("profile %q is not defined, available profiles: %s", e.Name, strings.Join(e.Available, ", "))
+}
+
+// Is makes the error match os.ErrNotExist.
+func (e *ProfileNotFoundError) Is(target error) bool {
+	return target == os.ErrNotExist
+}
+
+// ProfileNames returns the names of the profiles, sorted.
+func (c *Config) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(c.Profiles))
+}
+
+// GetProfile returns the named profile, merged with the profiles it extends.
 func (c *Config) GetProfile(name string) (*Profile, error) {
 
 	if name == "" {
 		return nil, fmt.Errorf("profile name is empty")
 	}
 
+	return c.resolveProfile(name, nil)
+}
+
+// resolveProfile merges the named profile with its bases. The chain holds the profiles
+// that extend it, to detect cycles.
+func (c *Config) resolveProfile(name string, chain []string) (*Profile, error) {
+	chain = append(chain, name)
+
 	profile, ok := c.Profiles[name]
 	if !ok {
-		return nil, os.ErrNotExist
+		err := &ProfileNotFoundError{Name: name, Available: c.ProfileNames()}
+
+		if len(chain) > 1 {
+			return nil, fmt.Errorf("profile %q extends an unknown profile: %w", chain[len(chain)-2], err)
+		}
+
+		return nil, err
+	}
+
+	if profile.Extends == "" {
+		return &profile, nil
+	}
+
+	if slices.Contains(chain, profile.Extends) {
+		return nil, fmt.Errorf("profiles extend each other in a cycle: %s -> %s", strings.Join(chain, " -> "), profile.Extends)
+	}
+
+	base, err := c.resolveProfile(profile.Extends, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := *base
+	merged.Extends = profile.Extends
+
+	if profile.Provider != "" {
+		merged.Provider = profile.Provider
+	}
+
+	if profile.Fallback != nil {
+		merged.Fallback = profile.Fallback
+	}
+
+	if profile.Timeout != nil {
+		merged.Timeout = profile.Timeout
+	}
+
+	if profile.FirstTokenTimeout != nil {
+		merged.FirstTokenTimeout = profile.FirstTokenTimeout
+	}
+
+	merged.Settings, err = jsonmap.DeepAssign(jsonmap.NewJsonMap(), base.Settings, profile.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &merged, nil
+}
+
+// checkTimeouts rejects negative timeouts, which would otherwise disable them without a
+// word. The config schema rejects them too, but it is not checked on every run.
+func (p *Profile) checkTimeouts() error {
+	if (p.Timeout != nil && *p.Timeout < 0) || (p.FirstTokenTimeout != nil && *p.FirstTokenTimeout < 0) {
+		return fmt.Errorf("timeout and first_token_timeout can't be negative")
+	}
+
+	return nil
+}
+
+// FallbackChain returns the profiles that are tried in order for the named profile: the
+// profile itself when it has a provider, followed by its fallback profiles and theirs.
+// Profiles that appear more than once are only tried the first time.
+func (c *Config) FallbackChain(name string) ([]string, error) {
+	var chain []string
+
+	if err := c.appendFallbacks(name, &chain, map[string]bool{}); err != nil {
+		return nil, err
+	}
+
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("profile %q has no provider and no fallback profiles", name)
+	}
+
+	return chain, nil
+}
+
+// appendFallbacks appends the named profile and its fallback profiles to chain. The
+// visited profiles are skipped, so profiles can fall back to each other.
+func (c *Config) appendFallbacks(name string, chain *[]string, visited map[string]bool) error {
+	if visited[name] {
+		return nil
+	}
+	visited[name] = true
+
+	profile, err := c.GetProfile(name)
+	if err != nil {
+		return err
+	}
+
+	if profile.Provider != "" {
+		*chain = append(*chain, name)
+	}
+
+	for _, fallback := range profile.Fallback {
+		if _, ok := c.Profiles[fallback]; !ok {
+			return fmt.Errorf("profile %q falls back to an unknown profile: %w", name, &ProfileNotFoundError{Name: fallback, Available: c.ProfileNames()})
+		}
+
+		if err := c.appendFallbacks(fallback, chain, visited); err != nil {
+			return err
+		}
 	}
-	return &profile, nil
+
+	return nil
 }
 
 func (c *Config) Save(fp string) error {
@@ -102,23 +273,54 @@ func (c *Config) Save(fp string) error {
 		return err
 	}
 
-	file, err := os.Create(fp)
+	// Keep the comments and layout of the loaded file when it is saved in the same format
+	file := c.file
+	if file == nil || file.Format() != configfile.FormatOf(fp) {
+		file = configfile.New(configfile.FormatOf(fp))
+	}
+
+	err = file.Update(c)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	err = encoder.Encode(c)
+	err = os.WriteFile(fp, file.Bytes(), 0o644)
 	if err != nil {
 		return err
 	}
 
+	c.file = file
+
 	return nil
 }
 
-func createNewConfigFile(fp string) (*Config, error) {
+// setConfigValue sets the value at path in the config file at fp and keeps the rest of
+// the file. The file is created when it does not exist.
+func setConfigValue(fp string, path []string, value any) error {
+	doc, file, err := readConfigDocument(fp)
+
+	if errors.Is(err, os.ErrNotExist) {
+		doc, file = map[string]any{}, configfile.New(configfile.FormatOf(fp))
+	} else if err != nil {
+		return err
+	}
+
+	if err := setPath(doc, path, value); err != nil {
+		return err
+	}
+
+	if err := file.Update(doc); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fp), os.ModePerm); err != nil {
+		return err
+	}
+
+	return os.WriteFile(fp, file.Bytes(), 0o644)
+}
+
+func createNewConfigFile(fp string, out *ui.UI) (*Config, error) {
 	// Create a new config file with default values
 	config := NewConfig()
 	err := config.Save(fp)
@@ -127,7 +329,7 @@ func createNewConfigFile(fp string) (*Config, error) {
 		return nil, err
 	}
 
-	fmt.Printf("Created new config file at %s\n", fp)
+	out.Printf("Created new config file at %s\n", fp)
 
 	return config, nil
 }
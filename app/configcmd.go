@@ -0,0 +1,508 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mcnull/qai/shared/configfile"
+	"github.com/mcnull/qai/shared/jsonc"
+	"github.com/mcnull/qai/shared/logging"
+	"github.com/mcnull/qai/shared/provider"
+	"github.com/mcnull/qai/shared/schema"
+)
+
+// SOURCE_DEFAULT is shown by "qai config show --effective" for values that are not configured.
+const SOURCE_DEFAULT = "default"
+
+func (app *App) runConfigGet(args []string) error {
+	fs := flag.NewFlagSet(APP_NAME+" config get", flag.ContinueOnError)
+	fs.SetOutput(app.Stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return &usageError{err: err}
+	}
+
+	if fs.NArg() != 1 {
+		return app.usageErrorf("usage: %s config get <key>", APP_NAME)
+	}
+
+	path, err := splitKey(fs.Arg(0))
+	if err != nil {
+		return app.usageErrorf("%v", err)
+	}
+
+	doc, _, err := readConfigDocument(app.Flags.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	value, ok := lookupPath(doc, path)
+	if !ok {
+		return fmt.Errorf("%s is not set in %s", fs.Arg(0), app.Flags.ConfigFile)
+	}
+
+	if s, ok := value.(string); ok {
+		_, err = fmt.Fprintln(app.Stdout, s)
+		return err
+	}
+
+	return app.writeJSON(value)
+}
+
+func (app *App) runConfigSet(args []string) error {
+	fs := flag.NewFlagSet(APP_NAME+" config set", flag.ContinueOnError)
+	fs.SetOutput(app.Stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return &usageError{err: err}
+	}
+
+	if fs.NArg() != 2 {
+		return app.usageErrorf("usage: %s config set <key> <value>", APP_NAME)
+	}
+
+	path, err := splitKey(fs.Arg(0))
+	if err != nil {
+		return app.usageErrorf("%v", err)
+	}
+
+	file := app.Flags.ConfigFile
+
+	doc, f, err := readConfigDocument(file)
+	if err != nil {
+		return err
+	}
+
+	if err := setPath(doc, path, parseValue(fs.Arg(1))); err != nil {
+		return fmt.Errorf("error setting %s: %w", fs.Arg(0), err)
+	}
+
+	// Only the changed key is rewritten, the rest of the file and its comments are kept
+	if err := f.Update(doc); err != nil {
+		return fmt.Errorf("error updating config: %w", err)
+	}
+
+	issues, err := ValidateConfig(f.Bytes(), f.Format())
+	if err != nil {
+		return fmt.Errorf("error validating config: %w", err)
+	}
+
+	if len(issues) > 0 {
+		for _, issue := range issues {
+			fmt.Fprintf(app.Stderr, "%s: %s\n", strings.Join(issue.Path, "."), issue.Message)
+		}
+		return fmt.Errorf("the config would have %d problem(s), %s was not changed", len(issues), file)
+	}
+
+	if err := os.WriteFile(file, f.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("error saving config: %w", err)
+	}
+
+	return nil
+}
+
+func (app *App) runConfigEdit(args []string) error {
+	fs := flag.NewFlagSet(APP_NAME+" config edit", flag.ContinueOnError)
+	fs.SetOutput(app.Stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return &usageError{err: err}
+	}
+
+	file := app.Flags.ConfigFile
+
+	original, err := os.ReadFile(file)
+
+	if os.IsNotExist(err) {
+		if _, err := createNewConfigFile(file, app.UI); err != nil {
+			return err
+		}
+		original, err = os.ReadFile(file)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error reading config: %w", err)
+	}
+
+	// The changes are only written to the config file once they are valid
+	tmp, err := os.CreateTemp(filepath.Dir(file), "config-*"+filepath.Ext(file))
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+
+	tmpPath := tmp.Name()
+
+	// Unless the edits are rejected, they are kept for the next session
+	keep := false
+	defer func() {
+		if !keep {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	_, err = tmp.Write(original)
+	tmp.Close()
+
+	if err != nil {
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+
+	answers := bufio.NewReader(app.Stdin)
+
+	for {
+		if err := app.runEditor(tmpPath); err != nil {
+			return fmt.Errorf("error running editor: %w", err)
+		}
+
+		data, err := os.ReadFile(tmpPath)
+		if err != nil {
+			return fmt.Errorf("error reading edited config: %w", err)
+		}
+
+		if bytes.Equal(data, original) {
+			app.UI.Println("No changes")
+			return nil
+		}
+
+		issues, err := ValidateConfig(data, configfile.FormatOf(file))
+		if err != nil {
+			return fmt.Errorf("error validating config: %w", err)
+		}
+
+		if len(issues) == 0 {
+			if err := os.WriteFile(file, data, 0o644); err != nil {
+				keep = true
+				return fmt.Errorf("error saving config, your edits were kept in %s: %w", tmpPath, err)
+			}
+
+			app.UI.Printf("Saved %s\n", file)
+			return nil
+		}
+
+		printIssues(app.Stderr, file, issues)
+
+		if !app.confirm(answers, "Edit again? [Y/n] ") {
+			keep = true
+			return fmt.Errorf("found %d problem(s), the config was not changed and your edits were kept in %s", len(issues), tmpPath)
+		}
+	}
+}
+
+// editor returns the command used by "qai config edit".
+func (app *App) editor() string {
+	for _, key := range []string{"VISUAL", "EDITOR"} {
+		if editor := app.getenv(key); editor != "" {
+			return editor
+		}
+	}
+
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+
+	return "vi"
+}
+
+// runEditor opens path in the editor and waits for it to exit. The editor may include
+// arguments, such as "code --wait".
+func (app *App) runEditor(path string) error {
+	editor := app.editor()
+
+	var cmd *exec.Cmd
+
+	if runtime.GOOS == "windows" {
+		fields := strings.Fields(editor)
+		cmd = exec.Command(fields[0], append(fields[1:], path)...)
+	} else {
+		cmd = exec.Command("sh", "-c", editor+` "$1"`, editor, path)
+	}
+
+	cmd.Stdin = app.Stdin
+	cmd.Stdout = app.Stdout
+	cmd.Stderr = app.Stderr
+
+	return cmd.Run()
+}
+
+// confirm asks a yes/no question on stderr. Anything but an explicit no is a yes,
+// except for the end of the input.
+func (app *App) confirm(answers *bufio.Reader, question string) bool {
+	app.UI.Printf("%s", question)
+
+	answer, err := answers.ReadString('\n')
+	if err != nil && (answer == "" || !errors.Is(err, io.EOF)) {
+		app.UI.Println()
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer != "n" && answer != "no"
+}
+
+func (app *App) runConfigShow(args []string) error {
+	fs := flag.NewFlagSet(APP_NAME+" config show", flag.ContinueOnError)
+	fs.SetOutput(app.Stderr)
+
+	effective := fs.Bool("effective", false, "Show the merged provider settings of the active profile and where each value comes from")
+
+	if err := fs.Parse(args); err != nil {
+		return &usageError{err: err}
+	}
+
+	if !*effective {
+		var doc any
+		if err := roundTrip(app.Config, &doc); err != nil {
+			return err
+		}
+		return app.writeJSON(maskSecrets(doc))
+	}
+
+	// The settings of the profile that is tried first
+	chain, err := app.Config.FallbackChain(app.Flags.Profile)
+	if err != nil {
+		return fmt.Errorf("error getting profile: %w", err)
+	}
+
+	name := chain[0]
+
+	profile, err := app.Config.GetProfile(name)
+	if err != nil {
+		return fmt.Errorf("error getting profile: %w", err)
+	}
+
+	factories, ok := providerFactories[profile.Provider]
+	if !ok {
+		return fmt.Errorf("error in profile \"%s\": %s", name, unknownProviderMessage(profile.Provider))
+	}
+
+	providerConfig := app.Config.Providers.Get(profile.Provider)
+
+	merged, err := provider.InitConfig(providerConfig, factories.config, profile.Settings)
+	if err != nil {
+		return fmt.Errorf("error initializing provider config: %w", err)
+	}
+
+	var settings map[string]any
+	if err := roundTrip(merged, &settings); err != nil {
+		return err
+	}
+
+	// The settings of the profile override those of the profiles it extends, which
+	// override those of the provider
+	var scopes []string
+	for n := name; n != ""; n = app.Config.Profiles[n].Extends {
+		scopes = append(scopes, fmt.Sprintf("profiles.%s.settings", n))
+	}
+	scopes = append(scopes, "providers."+profile.Provider)
+
+	layers := app.configLayers()
+
+	sources := map[string]string{}
+	for key := range settings {
+		sources[key] = settingSource(layers, scopes, key)
+	}
+
+	settings = maskSecrets(settings).(map[string]any)
+
+	if app.outputMode() == OUTPUT_JSON {
+		return app.writeJSON(map[string]any{
+			"profile":  name,
+			"provider": profile.Provider,
+			"settings": settings,
+			"sources":  sources,
+		})
+	}
+
+	fmt.Fprintf(app.Stdout, "Profile %q uses provider %q\n\n", name, profile.Provider)
+
+	tw := tabwriter.NewWriter(app.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		value, err := json.Marshal(settings[key])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", key, value, sources[key])
+	}
+
+	return tw.Flush()
+}
+
+// settingSource returns where the effective value of a provider setting comes from: the
+// first scope that sets it, with the last layer that sets it there.
+func settingSource(layers []configLayer, scopes []string, key string) string {
+	for _, scope := range scopes {
+		dotted := scope + "." + key
+
+		for i := len(layers) - 1; i >= 0; i-- {
+			if slices.ContainsFunc(layers[i].Keys, func(k string) bool { return k == dotted || strings.HasPrefix(k, dotted+".") }) {
+				return fmt.Sprintf("%s (%s)", scope, layers[i].Name)
+			}
+		}
+	}
+
+	return SOURCE_DEFAULT
+}
+
+func (app *App) writeJSON(v any) error {
+	encoder := json.NewEncoder(app.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(v)
+}
+
+// readConfigDocument reads the config file as a generic JSON document.
+func readConfigDocument(file string) (map[string]any, *configfile.File, error) {
+	f, err := configfile.Read(file)
+	if err != nil {
+		if _, ok := err.(*jsonc.SyntaxError); ok {
+			return nil, nil, fmt.Errorf("error parsing config, run \"%s config validate\" for details: %w", APP_NAME, err)
+		}
+		return nil, nil, fmt.Errorf("error reading config: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(f.JSON()))
+	decoder.UseNumber()
+
+	var doc map[string]any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("error parsing config, run \"%s config validate\" for details: %w", APP_NAME, err)
+	}
+
+	if doc == nil {
+		doc = map[string]any{}
+	}
+
+	return doc, f, nil
+}
+
+// splitKey splits a dotted key such as "profiles.work.settings.model" into its path.
+func splitKey(key string) ([]string, error) {
+	path := strings.Split(key, ".")
+
+	if slices.Contains(path, "") {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+
+	return path, nil
+}
+
+// lookupPath returns the value at path in doc.
+func lookupPath(doc any, path []string) (any, bool) {
+	for _, key := range path {
+		m, ok := doc.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		doc, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return doc, true
+}
+
+// setPath sets the value at path in doc, creating the objects leading to it.
+func setPath(doc map[string]any, path []string, value any) error {
+	for i, key := range path[:len(path)-1] {
+		child, ok := doc[key]
+
+		if !ok || child == nil {
+			child = map[string]any{}
+			doc[key] = child
+		}
+
+		m, ok := child.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s is not an object", strings.Join(path[:i+1], "."))
+		}
+
+		doc = m
+	}
+
+	doc[path[len(path)-1]] = value
+
+	return nil
+}
+
+// parseValue interprets a value given on the command line as JSON. Anything that is
+// not valid JSON is taken as a string, so quotes are only needed for strings like "true".
+func parseValue(s string) any {
+	var v any
+
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return s
+	}
+
+	return v
+}
+
+// roundTrip converts v to out through its JSON encoding.
+func roundTrip(v any, out any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// maskSecrets returns a copy of v with the values of secret keys such as tokens replaced.
+func maskSecrets(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		masked := make(map[string]any, len(value))
+		for k, child := range value {
+			if s, ok := child.(string); ok && s != "" && logging.IsSecretKey(k) {
+				masked[k] = logging.REDACTED
+				continue
+			}
+			masked[k] = maskSecrets(child)
+		}
+		return masked
+	case []any:
+		masked := make([]any, len(value))
+		for i, child := range value {
+			masked[i] = maskSecrets(child)
+		}
+		return masked
+	default:
+		return v
+	}
+}
+
+// printIssues writes the problems found in file to w.
+func printIssues(w io.Writer, file string, issues []schema.Issue) {
+	for _, issue := range issues {
+		if issue.Position.Line == 0 {
+			// TOML files have no positions
+			fmt.Fprintf(w, "%s: %s\n", file, issue)
+			continue
+		}
+		fmt.Fprintf(w, "%s:%s\n", file, issue)
+	}
+}
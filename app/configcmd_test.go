@@ -0,0 +1,513 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testWorkConfig = `{
+  "profile": "default",
+  "providers": { "github": { "token": "gho_secret", "model": "gpt-4o" } },
+  "profiles": {
+    "default": { "provider": "mock" },
+    "work": { "provider": "github", "settings": { "model": "o3", "host": "octo.ghe.com" } }
+  }
+}`
+
+func TestConfigGet(t *testing.T) {
+	home := newHome(t, testWorkConfig)
+
+	run := runApp(t, home, "", nil, "config", "get", "profiles.work.settings.model")
+	if run.code != EXIT_OK || run.stdout != "o3\n" {
+		t.Fatalf("unexpected result %d %q %q", run.code, run.stdout, run.stderr)
+	}
+
+	run = runApp(t, home, "", nil, "config", "get", "profiles.work.settings")
+	if run.code != EXIT_OK || !strings.Contains(run.stdout, `"host": "octo.ghe.com"`) {
+		t.Fatalf("unexpected result %d %q %q", run.code, run.stdout, run.stderr)
+	}
+
+	run = runApp(t, home, "", nil, "config", "get", "profiles.missing")
+	if run.code != EXIT_ERROR {
+		t.Fatalf("expected exit code %d for a missing key, got %d", EXIT_ERROR, run.code)
+	}
+
+	run = runApp(t, home, "", nil, "config", "get")
+	if run.code != EXIT_USAGE || !strings.Contains(run.stderr, "config get <key>") {
+		t.Fatalf("expected usage, got %d %q", run.code, run.stderr)
+	}
+}
+
+func TestConfigSet(t *testing.T) {
+	home := newHome(t, testWorkConfig)
+	file := homeConfigFile(home)
+
+	run := runApp(t, home, "", nil, "config", "set", "profile", "work")
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+
+	run = runApp(t, home, "", nil, "config", "set", "profiles.fast.provider", "ollama")
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+
+	run = runApp(t, home, "", nil, "config", "set", "profiles.fast.settings.seed", "42")
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+
+	config, err := LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Profile != "work" {
+		t.Fatalf("expected the default profile to be work, got %q", config.Profile)
+	}
+	if config.Profiles["fast"].Provider != "ollama" || config.Profiles["fast"].Settings["seed"] != float64(42) {
+		t.Fatalf("unexpected profile %+v", config.Profiles["fast"])
+	}
+
+	before, _ := os.ReadFile(file)
+
+	run = runApp(t, home, "", nil, "config", "set", "profiles.work.provider", "gihub")
+	if run.code != EXIT_ERROR || !strings.Contains(run.stderr, "profiles.work.provider") {
+		t.Fatalf("expected the invalid value to be rejected, got %d %q", run.code, run.stderr)
+	}
+
+	after, _ := os.ReadFile(file)
+	if string(before) != string(after) {
+		t.Fatal("the config was changed by an invalid value")
+	}
+}
+
+func TestConfigEdit(t *testing.T) {
+	home := newHome(t, testWorkConfig)
+	file := homeConfigFile(home)
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	invalid := filepath.Join(dir, "invalid.json")
+
+	os.WriteFile(valid, []byte(`{"profile": "work", "profiles": {"work": {"provider": "mock"}}}`), 0o644)
+	os.WriteFile(invalid, []byte(`{"profile": "work", "profiles": {}}`), 0o644)
+
+	// The editor is called with the file to edit as its last argument
+	run := runApp(t, home, "n\n", map[string]string{"EDITOR": "cp " + invalid}, "config", "edit")
+	if run.code != EXIT_ERROR || !strings.Contains(run.stderr, `profile "work" is not defined`) {
+		t.Fatalf("expected the invalid edit to be rejected, got %d %q", run.code, run.stderr)
+	}
+
+	if data, _ := os.ReadFile(file); string(data) != testWorkConfig {
+		t.Fatal("the config was changed by an invalid edit")
+	}
+
+	run = runApp(t, home, "", map[string]string{"VISUAL": "cp " + valid}, "config", "edit")
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+
+	if data, _ := os.ReadFile(file); !strings.Contains(string(data), `"provider": "mock"`) {
+		t.Fatalf("the edit was not saved, got %s", data)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(file), "config-*"))
+	if len(matches) != 1 {
+		t.Fatalf("expected only the edits of the rejected session to be kept, got %q", matches)
+	}
+
+	// An editor that fails leaves no temporary file behind
+	run = runApp(t, home, "", map[string]string{"VISUAL": "false"}, "config", "edit")
+	if run.code != EXIT_ERROR || !strings.Contains(run.stderr, "error running editor") {
+		t.Fatalf("expected the editor error, got %d %q", run.code, run.stderr)
+	}
+
+	if after, _ := filepath.Glob(filepath.Join(filepath.Dir(file), "config-*")); len(after) != 1 {
+		t.Fatalf("expected the temporary file to be removed, got %q", after)
+	}
+}
+
+func TestConfigShowEffective(t *testing.T) {
+	home := newHome(t, testWorkConfig)
+
+	run := runApp(t, home, "", nil, "--profile", "work", "config", "show", "--effective")
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+
+	if strings.Contains(run.stdout, "gho_secret") {
+		t.Fatalf("the token was not masked: %s", run.stdout)
+	}
+
+	rows := func(stdout string) [][]string {
+		var rows [][]string
+		for _, line := range strings.Split(stdout, "\n")[3:] {
+			if fields := strings.Fields(line); len(fields) > 0 {
+				rows = append(rows, fields)
+			}
+		}
+		return rows
+	}
+
+	want := [][]string{
+		{"host", `"octo.ghe.com"`, "profiles.work.settings", "(user)"},
+		{"model", `"o3"`, "profiles.work.settings", "(user)"},
+		{"token", `"[REDACTED]"`, "providers.github", "(user)"},
+	}
+
+	if got := rows(run.stdout); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected settings %q", got)
+	}
+
+	// The sources follow the layers and the profiles that are extended
+	os.WriteFile(filepath.Join(home, PROJECT_FILENAME), []byte(`{
+  "profiles": {
+    "work": { "settings": { "model": "o4-mini" } },
+    "review": { "extends": "work", "settings": { "model": "gpt-4.1" } }
+  }
+}`), 0o644)
+
+	for profile, want := range map[string][][]string{
+		"work": {
+			{"host", `"octo.ghe.com"`, "profiles.work.settings", "(user)"},
+			{"model", `"o4-mini"`, "profiles.work.settings", "(project)"},
+			{"token", `"[REDACTED]"`, "providers.github", "(user)"},
+		},
+		"review": {
+			{"host", `"octo.ghe.com"`, "profiles.work.settings", "(user)"},
+			{"model", `"gpt-4.1"`, "profiles.review.settings", "(project)"},
+			{"token", `"[REDACTED]"`, "providers.github", "(user)"},
+		},
+	} {
+		run = runApp(t, home, "", nil, "--profile", profile, "config", "show", "--effective")
+		if run.code != EXIT_OK {
+			t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+		}
+
+		if got := rows(run.stdout); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: unexpected settings %q", profile, got)
+		}
+	}
+}
+
+func TestConfigFormats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `# Answers without a model
+version: 1
+profile: default
+profiles:
+  default:
+    provider: mock # canned responses
+    settings:
+      response: Hello
+`,
+		"config.jsonc": `{
+  // Answers without a model
+  "version": 1,
+  "profile": "default",
+  "profiles": {
+    "default": {
+      "provider": "mock", // canned responses
+      "settings": { "response": "Hello" },
+    },
+  },
+}
+`,
+		"config.toml": `version = 1
+profile = "default"
+
+[profiles.default]
+provider = "mock"
+
+[profiles.default.settings]
+response = "Hello"
+`,
+	}
+
+	for name, config := range files {
+		home := t.TempDir()
+		file := filepath.Join(home, ".config", "qai", name)
+
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		run := runApp(t, home, "", nil, "-output", "raw", "hi")
+		if run.code != EXIT_OK || run.stdout != "Hello\n" {
+			t.Fatalf("%s: unexpected result %d %q %q", name, run.code, run.stdout, run.stderr)
+		}
+
+		run = runApp(t, home, "", nil, "config", "set", "profiles.default.settings.response", "Bye")
+		if run.code != EXIT_OK {
+			t.Fatalf("%s: exit code %d, stderr: %s", name, run.code, run.stderr)
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := strings.Replace(config, "Hello", "Bye", 1)
+		if !strings.HasSuffix(name, ".toml") && string(data) != want {
+			t.Fatalf("%s: expected only the value to change, got\n%s", name, data)
+		}
+
+		run = runApp(t, home, "", nil, "-output", "raw", "hi")
+		if run.code != EXIT_OK || run.stdout != "Bye\n" {
+			t.Fatalf("%s: unexpected result after set %d %q %q", name, run.code, run.stdout, run.stderr)
+		}
+	}
+}
+
+func TestConfigLayers(t *testing.T) {
+	home := newHome(t, `{
+  "profile": "default",
+  "profiles": {
+    "default": { "provider": "mock", "settings": { "response": "user" } },
+    "echo": { "provider": "mock" }
+  }
+}`)
+
+	system := t.TempDir()
+
+	os.WriteFile(filepath.Join(system, "config.yaml"), []byte("system: From the system config\nprofiles:\n  default:\n    settings:\n      chunk_size: 2\n"), 0o644)
+
+	// The project file is found in a parent of the working directory
+	project := t.TempDir()
+	work := filepath.Join(project, "src", "pkg")
+	os.MkdirAll(work, 0o755)
+	os.WriteFile(filepath.Join(project, ".qai.json"), []byte(`{
+  // Checked in with the project
+  "profiles": { "default": { "settings": { "response": "project" } } },
+}`), 0o644)
+
+	run := func(env map[string]string, args ...string) testRun {
+		environ := []string{"HOME=" + home}
+		for k, v := range env {
+			environ = append(environ, k+"="+v)
+		}
+
+		var stdout, stderr strings.Builder
+
+		app := NewApp().
+			WithIO(strings.NewReader(""), &stdout, &stderr).
+			WithEnv(environ).
+			WithWorkDir(work).
+			WithSystemConfigDir(system)
+
+		code := app.Main(append([]string{"qai"}, args...))
+
+		return testRun{code: code, stdout: stdout.String(), stderr: stderr.String()}
+	}
+
+	result := run(nil, "-output", "raw", "hi")
+	if result.code != EXIT_OK || result.stdout != "project\n" {
+		t.Fatalf("expected the project config to override the user config, got %d %q %q", result.code, result.stdout, result.stderr)
+	}
+
+	result = run(map[string]string{"QAI_PROFILES__DEFAULT__SETTINGS__RESPONSE": "env"}, "-output", "raw", "hi")
+	if result.code != EXIT_OK || result.stdout != "env\n" {
+		t.Fatalf("expected the environment to override the project config, got %d %q %q", result.code, result.stdout, result.stderr)
+	}
+
+	result = run(map[string]string{"QAI_PROFILE": "echo", "QAI_SECRET_KEY": "ignored"}, "-output", "raw", "hi")
+	if result.code != EXIT_OK || result.stdout != "hi\n" {
+		t.Fatalf("expected QAI_PROFILE to select the echo profile, got %d %q %q", result.code, result.stdout, result.stderr)
+	}
+
+	result = run(map[string]string{"QAI_PROFILE": "echo"}, "-output", "json", "config", "sources")
+	if result.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", result.code, result.stderr)
+	}
+
+	var layers []configLayer
+	if err := json.Unmarshal([]byte(result.stdout), &layers); err != nil {
+		t.Fatalf("invalid JSON %q: %v", result.stdout, err)
+	}
+
+	want := []struct {
+		name, source string
+		keys         []string
+	}{
+		{LAYER_SYSTEM, filepath.Join(system, "config.yaml"), []string{"profiles.default.settings.chunk_size", "system"}},
+		{LAYER_USER, homeConfigFile(home), nil},
+		{LAYER_PROJECT, filepath.Join(project, ".qai.json"), []string{"profiles.default.settings.response"}},
+		{LAYER_ENV, "QAI_PROFILE", []string{"profile"}},
+	}
+
+	if len(layers) != len(want) {
+		t.Fatalf("expected %d layers, got %+v", len(want), layers)
+	}
+
+	for i, w := range want {
+		l := layers[i]
+		if l.Name != w.name || l.Source != w.source || !l.Found {
+			t.Fatalf("layer %d: expected %s from %s, got %+v", i, w.name, w.source, l)
+		}
+		if w.keys != nil && !reflect.DeepEqual(l.Keys, w.keys) {
+			t.Fatalf("layer %s: expected keys %v, got %v", l.Name, w.keys, l.Keys)
+		}
+	}
+
+	// The settings of a profile are merged across layers
+	result = run(nil, "config", "show")
+	if !strings.Contains(result.stdout, `"chunk_size": 2`) || !strings.Contains(result.stdout, `"response": "project"`) {
+		t.Fatalf("expected the merged profile, got %q", result.stdout)
+	}
+}
+
+func TestXDGConfigHome(t *testing.T) {
+	xdg := t.TempDir()
+
+	run := runApp(t, t.TempDir(), "", map[string]string{"XDG_CONFIG_HOME": xdg}, "--version")
+	if !strings.Contains(run.stdout, filepath.Join(xdg, "qai", CONFIG_FILENAME)) {
+		t.Fatalf("expected the config file in XDG_CONFIG_HOME, got %q", run.stdout)
+	}
+}
+
+func TestProjectConfigEndpoints(t *testing.T) {
+	var evilRequests int
+	evil := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		evilRequests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer evil.Close()
+
+	var tokens []string
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer good.Close()
+
+	home := newHome(t, fmt.Sprintf(`{
+  "profile": "work",
+  "providers": { "github": { "token": "gho_secret", "api_host": %q, "chat_url": %q } },
+  "profiles": { "work": { "provider": "github" } }
+}`, good.URL, good.URL+"/chat/completions"))
+
+	// The working directory of runApp is the home directory
+	os.WriteFile(filepath.Join(home, PROJECT_FILENAME), []byte(fmt.Sprintf(`{
+  "system": "From the project",
+  "providers": { "github": { "api_host": %[1]q, "chat_url": %[1]q } },
+  "usage": { "disabled": true },
+  "profiles": { "work": { "settings": { "model": "o3", "api_host": %[1]q, "chat_url": %[1]q, "token": "gho_other" } } }
+}`, evil.URL)), 0o644)
+
+	run := runApp(t, home, "", nil, "-retries", "0", "-output", "raw", "hi")
+	if run.code != EXIT_AUTH {
+		t.Fatalf("expected the configured host to reject the token, got %d %q", run.code, run.stderr)
+	}
+
+	if evilRequests != 0 {
+		t.Fatalf("the project config sent %d requests to its own host", evilRequests)
+	}
+	if len(tokens) == 0 || !strings.Contains(tokens[0], "gho_secret") {
+		t.Fatalf("expected the user token at the configured host, got %v", tokens)
+	}
+
+	for _, key := range []string{"profiles.work.settings.api_host", "profiles.work.settings.chat_url", "profiles.work.settings.token", "providers.github.api_host", "usage.disabled"} {
+		if !strings.Contains(run.stderr, key) {
+			t.Fatalf("expected a warning about %s, got %q", key, run.stderr)
+		}
+	}
+
+	run = runApp(t, home, "", nil, "config", "show")
+	if !strings.Contains(run.stdout, `"model": "o3"`) || !strings.Contains(run.stdout, `"system": "From the project"`) {
+		t.Fatalf("expected the allowed keys of the project config, got %q", run.stdout)
+	}
+}
+
+// A project config can't point a provider at a file of the user.
+func TestProjectConfigFilePaths(t *testing.T) {
+	responses := filepath.Join(t.TempDir(), "responses.json")
+	os.WriteFile(responses, []byte(`{".*": "from a file"}`), 0o644)
+
+	home := newHome(t, `{
+  "profile": "local",
+  "profiles": { "local": { "provider": "mock", "settings": { "response": "from the user" } } }
+}`)
+
+	os.WriteFile(filepath.Join(home, PROJECT_FILENAME), []byte(fmt.Sprintf(`{
+  "profiles": {
+    "local": { "settings": { "responses": %[1]q, "chunk_size": 2 } },
+    "other": { "extends": "local", "settings": { "responses": %[1]q } }
+  }
+}`, responses)), 0o644)
+
+	for _, profile := range []string{"local", "other"} {
+		run := runApp(t, home, "", nil, "-output", "raw", "-profile", profile, "hi")
+		if run.code != EXIT_OK || run.stdout != "from the user\n" {
+			t.Fatalf("%s: expected the responses file to be ignored, got %d %q %q", profile, run.code, run.stdout, run.stderr)
+		}
+
+		if !strings.Contains(run.stderr, "profiles."+profile+".settings.responses") || strings.Contains(run.stderr, "chunk_size") {
+			t.Fatalf("%s: expected a warning about the responses file only, got %q", profile, run.stderr)
+		}
+	}
+}
+
+func TestConfigValidateLayers(t *testing.T) {
+	home := newHome(t, `{
+  "profile": "local",
+  "profiles": { "local": { "provider": "mock" } }
+}`)
+	file := homeConfigFile(home)
+	project := filepath.Join(home, PROJECT_FILENAME)
+
+	// The project extends a profile of the user config and selects it
+	os.WriteFile(project, []byte(`{
+  "profile": "work",
+  "profiles": {
+    "work": { "extends": "local", "modle": "x" },
+    "broken": { "extends": "missing" }
+  }
+}`), 0o644)
+
+	run := runApp(t, home, "", map[string]string{"QAI_PROFILES__LOCAL__TIMEOUT": "soon"}, "config", "validate")
+
+	for _, want := range []string{
+		project + ":4:35: profiles.work.modle: unknown property",
+		project + `:5:28: profiles.broken.extends: profile "broken" extends an unknown profile`,
+		"QAI_PROFILES__LOCAL__TIMEOUT: profiles.local.timeout:",
+	} {
+		if !strings.Contains(run.stdout, want) {
+			t.Fatalf("expected %q in %q", want, run.stdout)
+		}
+	}
+
+	if run.code != EXIT_ERROR || strings.Contains(run.stdout, file) || strings.Contains(run.stdout, `"work" is not defined`) {
+		t.Fatalf("expected only the project and the environment to be invalid, got %d %q", run.code, run.stdout)
+	}
+	if !strings.Contains(run.stderr, "found 3 problem(s) in "+project+", QAI_* environment variables") {
+		t.Fatalf("expected the invalid layers in the error, got %q", run.stderr)
+	}
+
+	// A syntax error in any layer is reported
+	os.WriteFile(project, []byte(`{ "profile": }`), 0o644)
+
+	run = runApp(t, home, "", nil, "config", "validate")
+
+	if run.code != EXIT_ERROR || !strings.Contains(run.stdout, project+":1:14:") {
+		t.Fatalf("expected the syntax error of the project config, got %d %q", run.code, run.stdout)
+	}
+
+	os.Remove(project)
+
+	run = runApp(t, home, "", nil, "config", "validate")
+
+	if run.code != EXIT_OK || !strings.Contains(run.stderr, file+" is valid") {
+		t.Fatalf("expected a valid config, got %d %q %q", run.code, run.stdout, run.stderr)
+	}
+}
@@ -3,23 +3,64 @@ package app
 import (
 	"os"
 	"path/filepath"
-)
+	"strings"
+	"time"
 
-var DEFAULT_CONFIG_FILEPATH string
+	"github.com/mcnull/qai/shared/configfile"
+)
 
 const (
 	APP_NAME              = "qai"
 	APP_VERSION           = "0.5.2"
+	CONFIG_FILENAME       = "config.json"
+	PROJECT_FILENAME      = ".qai.json"
 	DEFAULT_PROFILE       = "default"
+	DEFAULT_TIMEOUT       = 5 * time.Minute
 	DEFAULT_SYSTEM_PROMPT = "The user is running a terminal in the following environment: {{.Platform}}.\nYour responses are {{.Verbose}}."
 )
 
-func init() {
-	// Resolve "$HOME/.config/github.com/mcnull/qai" to the config directory (windows/linux/mac)
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
+// defaultConfigFilePath resolves "$XDG_CONFIG_HOME/qai/config.json", or
+// "$HOME/.config/qai/config.json" when XDG_CONFIG_HOME is not set (windows/linux/mac).
+// The home directory is looked up in the environment first so it can be overridden.
+// An existing config file with another supported extension, such as config.yaml, is used
+// instead.
+func defaultConfigFilePath(getenv func(string) string) string {
+	// Relative paths are ignored, as the XDG specification requires
+	if dir := getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		fp, _ := findConfigFile(filepath.Join(dir, APP_NAME), CONFIG_FILENAME)
+		return fp
+	}
+
+	homeDir := getenv("HOME")
+
+	if homeDir == "" {
+		homeDir = getenv("USERPROFILE")
+	}
+
+	if homeDir == "" {
+		var err error
+		homeDir, err = os.UserHomeDir()
+		if err != nil {
+			homeDir = "."
+		}
 	}
-	dir := filepath.Join(homeDir, ".config", "qai")
-	DEFAULT_CONFIG_FILEPATH = filepath.Join(dir, "config.json")
+
+	fp, _ := findConfigFile(filepath.Join(homeDir, ".config", APP_NAME), CONFIG_FILENAME)
+	return fp
+}
+
+// findConfigFile returns the path of the file named filename in dir, or of an existing
+// file with the same name and another supported extension. The path of filename is
+// returned when none exists.
+func findConfigFile(dir string, filename string) (string, bool) {
+	name := strings.TrimSuffix(filename, filepath.Ext(filename))
+
+	for _, ext := range configfile.EXTENSIONS {
+		fp := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(fp); err == nil {
+			return fp, true
+		}
+	}
+
+	return filepath.Join(dir, filename), false
 }
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+// Exit codes returned by qai, so scripts can react to the kind of failure.
+const (
+	EXIT_OK         = 0
+	EXIT_ERROR      = 1
+	EXIT_USAGE      = 2
+	EXIT_AUTH       = 3
+	EXIT_CONNECTION = 4
+	EXIT_RATE_LIMIT = 5
+	EXIT_TIMEOUT    = 6
+	EXIT_REQUEST    = 7
+	EXIT_SERVER     = 8
+	EXIT_CANCELLED  = 130
+)
+
+// ExitCode returns the process exit code for err.
+func ExitCode(err error) int {
+	if err == nil {
+		return EXIT_OK
+	}
+
+	var uErr *usageError
+	if errors.As(err, &uErr) {
+		return EXIT_USAGE
+	}
+
+	pErr, ok := provider.AsError(err)
+	if !ok {
+		return EXIT_ERROR
+	}
+
+	switch pErr.Class {
+	case provider.ErrorClassAuth:
+		return EXIT_AUTH
+	case provider.ErrorClassConnection:
+		return EXIT_CONNECTION
+	case provider.ErrorClassRateLimit:
+		return EXIT_RATE_LIMIT
+	case provider.ErrorClassTimeout:
+		return EXIT_TIMEOUT
+	case provider.ErrorClassRequest:
+		return EXIT_REQUEST
+	case provider.ErrorClassServer:
+		return EXIT_SERVER
+	case provider.ErrorClassCancelled:
+		return EXIT_CANCELLED
+	default:
+		return EXIT_ERROR
+	}
+}
+
+// usageError is returned for invalid command line flags. The flag parser has
+// already reported it together with the usage.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// usageErrorf reports a usage error on stderr. The returned error is not printed again.
+func (app *App) usageErrorf(format string, a ...any) error {
+	err := fmt.Errorf(format, a...)
+	fmt.Fprintln(app.Stderr, err)
+	return &usageError{err: err}
+}
+
+// PrintError writes err to w, followed by a hint on how to resolve it when one is available.
+func PrintError(w io.Writer, prefix string, err error) {
+	fmt.Fprintf(w, "%s: %v\n", prefix, err)
+
+	if pErr, ok := provider.AsError(err); ok && pErr.Hint != "" {
+		fmt.Fprintf(w, "Hint: %s\n", pErr.Hint)
+	}
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"github.com/mcnull/qai/shared/provider"
+)
+
+// nextProvider switches to the next profile of the fallback chain. err is the reason the
+// current profile failed, nil for the first profile. A profile that fails to initialize
+// with an error that allows a fallback is skipped as well.
+func (app *App) nextProvider(err error) error {
+
+	for len(app.fallbacks) > 0 {
+		name := app.fallbacks[0]
+		app.fallbacks = app.fallbacks[1:]
+
+		if err != nil {
+			app.Logger.Warn("falling back to the next profile", "profile", app.profileName, "next", name, "error", err.Error())
+			app.UI.Printf("Profile %q failed: %v, using profile %q\n", app.profileName, err, name)
+		}
+
+		err = app.useProfile(name)
+
+		if err == nil || !canFallBack(err) {
+			return err
+		}
+
+		app.profileName = name
+	}
+
+	return err
+}
+
+// canFallBack reports whether a request that failed with err may be sent to the next
+// profile of the fallback chain: the provider could not be reached, rejected the
+// credentials or did not answer in time.
+func canFallBack(err error) bool {
+	pErr, ok := provider.AsError(err)
+	if !ok {
+		return false
+	}
+
+	switch pErr.Class {
+	case provider.ErrorClassConnection, provider.ErrorClassAuth, provider.ErrorClassTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testFallbackConfig = `{
+  "profile": "resilient",
+  "profiles": {
+    "offline": { "provider": "mock", "settings": { "error_at": 0, "error": "connection", "error_message": "no network" } },
+    "denied": { "provider": "mock", "settings": { "error_at": 0, "error": "auth", "error_message": "bad token" } },
+    "broken": { "provider": "mock", "settings": { "error_at": 0, "error": "server", "error_message": "internal error" } },
+    "partial": { "provider": "mock", "settings": { "response": "partial answer", "chunk_size": 4, "error_at": 1, "error": "connection" } },
+    "local": { "provider": "mock", "settings": { "response": "from local" } },
+    "resilient": { "fallback": ["offline", "denied", "local"] },
+    "primary": { "extends": "offline", "fallback": ["local"] },
+    "no-retry": { "fallback": ["broken", "local"] },
+    "too-late": { "fallback": ["partial", "local"] },
+    "all-down": { "fallback": ["offline", "denied"] },
+    "ping": { "provider": "mock", "fallback": ["pong"] },
+    "pong": { "fallback": ["ping", "local"] },
+    "unknown": { "fallback": ["missing"] }
+  }
+}`
+
+func TestFallbackChain(t *testing.T) {
+	config := NewConfig()
+	if err := json.Unmarshal([]byte(testFallbackConfig), config); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"local":     {"local"},
+		"resilient": {"offline", "denied", "local"},
+		"primary":   {"primary", "local"},
+		"ping":      {"ping", "local"},
+		"pong":      {"ping", "local"},
+	}
+
+	for name, want := range tests {
+		chain, err := config.FallbackChain(name)
+		if err != nil {
+			t.Fatalf("%s: FallbackChain failed: %v", name, err)
+		}
+		if !slices.Equal(chain, want) {
+			t.Fatalf("%s: expected %v, got %v", name, want, chain)
+		}
+	}
+
+	if _, err := config.FallbackChain("unknown"); err == nil || !strings.Contains(err.Error(), `profile "unknown" falls back to an unknown profile`) {
+		t.Fatalf("expected an unknown fallback error, got %v", err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	home := newHome(t, testFallbackConfig)
+
+	run := runApp(t, home, "", nil, "-output", "json", "hi")
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+
+	var result Result
+	if err := json.Unmarshal([]byte(run.stdout), &result); err != nil {
+		t.Fatalf("invalid JSON %q: %v", run.stdout, err)
+	}
+	if result.Profile != "local" || result.Response != "from local" {
+		t.Fatalf("expected the answer of the local profile, got %+v", result)
+	}
+
+	for _, message := range []string{
+		`Profile "offline" failed: mock: no network, using profile "denied"`,
+		`Profile "denied" failed: mock: bad token, using profile "local"`,
+	} {
+		if !strings.Contains(run.stderr, message) {
+			t.Fatalf("expected %q in %q", message, run.stderr)
+		}
+	}
+
+	// A profile with a provider is tried before its fallback profiles
+	run = runApp(t, home, "", nil, "-output", "raw", "-profile", "primary", "hi")
+	if run.code != EXIT_OK || run.stdout != "from local\n" || !strings.Contains(run.stderr, `Profile "primary" failed`) {
+		t.Fatalf("unexpected result %d %q %q", run.code, run.stdout, run.stderr)
+	}
+
+	// Other errors and errors after the first output are not retried
+	run = runApp(t, home, "", nil, "-output", "raw", "-profile", "no-retry", "hi")
+	if run.code != EXIT_SERVER || strings.Contains(run.stderr, "using profile") {
+		t.Fatalf("expected the server error without a fallback, got %d %q", run.code, run.stderr)
+	}
+
+	run = runApp(t, home, "", nil, "-output", "raw", "-profile", "too-late", "hi")
+	if run.code != EXIT_CONNECTION || run.stdout != "part\n" || strings.Contains(run.stderr, "using profile") {
+		t.Fatalf("expected the partial answer without a fallback, got %d %q %q", run.code, run.stdout, run.stderr)
+	}
+
+	// The error of the last profile is reported when all of them fail
+	run = runApp(t, home, "", nil, "-output", "raw", "-profile", "all-down", "hi")
+	if run.code != EXIT_AUTH || !strings.Contains(run.stderr, "bad token") {
+		t.Fatalf("expected the error of the last profile, got %d %q", run.code, run.stderr)
+	}
+
+	run = runApp(t, home, "", nil, "config", "validate")
+	if run.code != EXIT_ERROR || !strings.Contains(run.stdout, `profiles.unknown.fallback: profile "unknown" falls back to an unknown profile`) {
+		t.Fatalf("expected the unknown fallback to be reported, got %d %q", run.code, run.stdout)
+	}
+}
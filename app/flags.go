@@ -1,25 +1,94 @@
 package app
 
 import (
+	"flag"
+	"io"
+	"slices"
+	"strings"
+
 	"github.com/mcnull/qai/shared/envflags"
 	"github.com/mcnull/qai/shared/provider"
-	"strings"
+	"github.com/mcnull/qai/shared/terminal"
 )
 
-func parseFlags(args []string, exitOnError bool, values *provider.FlagValues) (*provider.FlagValues, error) {
+// bareEnvFlags are the flags that have always been read from an environment variable of
+// the same name, such as PROFILE. The other flags use the ENV_PREFIX, as in QAI_OUTPUT,
+// since names like TIMEOUT or OUTPUT are commonly set for other programs.
+var bareEnvFlags = []string{"config", "create-config", "profile", "system", "debug", "debug-stream", "color", "verbose", "github-login", "version"}
+
+func parseFlags(args []string, exitOnError bool, values *provider.FlagValues, lookupEnv envflags.LookupEnvFunc, output io.Writer) (*provider.FlagValues, error) {
 
 	fs := provider.CreateFlagSet(APP_NAME, values, exitOnError)
+	fs.SetOutput(output)
 
 	options := envflags.NewParseOptions(fs)
+	options.LookupEnv = lookupEnv
+	options.FlagToEnvKey = flagEnvKey
 
 	// Parse the command line arguments and merge them with environment variables
-	remainingArgs, err := envflags.Parse(args, options)
+	remainingArgs, err := envflags.Parse(joinColorMode(fs, args), options)
 
 	if err != nil {
 		return nil, err
 	}
 
+	values.Args = remainingArgs
 	values.Prompt = strings.Join(remainingArgs, " ")
 
 	return values, nil
 }
+
+// joinColorMode turns "-color always" into "-color=always". -color is a boolean flag so
+// that a bare -color enables colors, and the flag package would otherwise take the mode
+// for the prompt. Only the flags before the prompt are looked at.
+func joinColorMode(fs *flag.FlagSet, args []string) []string {
+	joined := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" || arg == "-" || !strings.HasPrefix(arg, "-") {
+			return append(joined, args[i:]...)
+		}
+
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		hasNext := !hasValue && i+1 < len(args)
+
+		if name == "color" && hasNext && isColorMode(args[i+1]) {
+			joined = append(joined, arg+"="+args[i+1])
+			i++
+			continue
+		}
+
+		joined = append(joined, arg)
+
+		// Keep the value of other flags, it may look like a prompt
+		if f := fs.Lookup(name); f != nil && hasNext && !isBoolFlag(f) {
+			joined = append(joined, args[i+1])
+			i++
+		}
+	}
+
+	return joined
+}
+
+func isColorMode(s string) bool {
+	_, err := terminal.ParseColorMode(s)
+	return s != "" && err == nil
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// flagEnvKey returns the environment variable of a flag, see bareEnvFlags.
+func flagEnvKey(f *flag.Flag) (string, error) {
+	key := strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+
+	if slices.Contains(bareEnvFlags, f.Name) {
+		return key, nil
+	}
+
+	return ENV_PREFIX + key, nil
+}
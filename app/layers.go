@@ -0,0 +1,394 @@
+package app
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mcnull/qai/shared/jsonc"
+	"github.com/mcnull/qai/shared/jsonmap"
+)
+
+// The layers of the config, from the lowest to the highest precedence
+const (
+	LAYER_SYSTEM  = "system"
+	LAYER_USER    = "user"
+	LAYER_PROJECT = "project"
+	LAYER_ENV     = "env"
+)
+
+// ENV_PREFIX starts the names of the environment variables that set config values,
+// such as QAI_PROFILE. Nested keys are separated by ENV_SEPARATOR, as in
+// QAI_PROFILES__WORK__SETTINGS__MODEL.
+const (
+	ENV_PREFIX    = "QAI_"
+	ENV_SEPARATOR = "__"
+)
+
+// projectKeys are the top-level keys a project config may set, and projectProfileKeys
+// the keys of its profiles. A project file comes with a cloned repository, so it can't
+// change the providers, their endpoints or credentials, or the usage ledger.
+var (
+	projectKeys        = []string{"$schema", "version", "profile", "system", "profiles"}
+	projectProfileKeys = []string{"extends", "fallback", "provider", "settings", "timeout", "first_token_timeout"}
+)
+
+// systemConfigDir returns the directory of the system config of the platform.
+func systemConfigDir(getenv func(string) string) string {
+	if runtime.GOOS == "windows" {
+		if dir := getenv("ProgramData"); dir != "" {
+			return filepath.Join(dir, APP_NAME)
+		}
+	}
+
+	return filepath.Join("/etc", APP_NAME)
+}
+
+// configLayer is one of the sources of the config. The layers are merged in order: objects
+// are merged key by key and other values of a later layer replace those of earlier ones.
+type configLayer struct {
+	Name string `json:"name"`
+	// The config file, or the environment variables that are set
+	Source string `json:"source"`
+	Found  bool   `json:"found"`
+	// The dotted keys of the values set by the layer
+	Keys []string `json:"keys"`
+	// The dotted keys the layer is not allowed to set, see restrictProjectConfig
+	Ignored []string `json:"ignored,omitempty"`
+	Error   string   `json:"error,omitempty"`
+
+	doc map[string]any
+	// The version of the config format of the file, before it was migrated
+	version int
+}
+
+// configLayers reads the system, user and project config files and the QAI_* environment
+// variables. A layer that can't be read has its Error set.
+func (app *App) configLayers() []configLayer {
+	systemDir := app.systemDir
+	if systemDir == "" {
+		systemDir = systemConfigDir(app.getenv)
+	}
+
+	systemFile, _ := findConfigFile(systemDir, CONFIG_FILENAME)
+	projectFile := app.projectConfigFile()
+
+	if projectFile == app.Flags.ConfigFile {
+		// Already read as the user config
+		projectFile = ""
+	}
+
+	var layers []configLayer
+
+	for _, f := range []struct{ name, path string }{
+		{LAYER_SYSTEM, systemFile},
+		{LAYER_USER, app.Flags.ConfigFile},
+		{LAYER_PROJECT, projectFile},
+	} {
+		layer := configLayer{Name: f.name, Source: f.path, Keys: []string{}}
+
+		if f.path != "" {
+			doc, _, err := readConfigDocument(f.path)
+
+			var sErr *jsonc.SyntaxError
+
+			switch {
+			case err == nil:
+				layer.Found = true
+				layer.doc = doc
+				layer.Keys = leafKeys(doc, nil)
+
+				// Files are upgraded in memory, only the user config is rewritten
+				if layer.version, err = migrateConfig(doc); err != nil {
+					layer.Error = fmt.Sprintf("%s: %v", f.path, err)
+				}
+
+				if f.name == LAYER_PROJECT {
+					var base []map[string]any
+					for _, l := range layers {
+						base = append(base, l.doc)
+					}
+
+					layer.Ignored = restrictProjectConfig(doc, base)
+					layer.Keys = slices.DeleteFunc(layer.Keys, func(key string) bool { return slices.Contains(layer.Ignored, key) })
+				}
+			case errors.As(err, &sErr):
+				layer.Error = fmt.Sprintf("%s:%s, run \"%s config validate\" for details", f.path, sErr, APP_NAME)
+			case !errors.Is(err, os.ErrNotExist):
+				layer.Error = err.Error()
+			}
+		}
+
+		layers = append(layers, layer)
+	}
+
+	return append(layers, app.envLayer())
+}
+
+// restrictProjectConfig removes the keys a project config is not allowed to set from doc
+// and returns them as dotted keys. Only the provider settings of projectSettings are kept,
+// the credentials of the user would otherwise be sent to a host chosen by the project.
+// The provider of a profile may come from the docs of the layers below, in base.
+func restrictProjectConfig(doc map[string]any, base []map[string]any) []string {
+	var ignored []string
+
+	remove := func(m map[string]any, path []string, allowed func(key string) bool) {
+		for key := range m {
+			if !allowed(key) {
+				ignored = append(ignored, leafKeys(map[string]any{key: m[key]}, path)...)
+				delete(m, key)
+			}
+		}
+	}
+
+	remove(doc, nil, func(key string) bool { return slices.Contains(projectKeys, key) })
+
+	profiles, _ := doc["profiles"].(map[string]any)
+
+	for name, value := range profiles {
+		profile, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		path := []string{"profiles", name}
+		remove(profile, path, func(key string) bool { return slices.Contains(projectProfileKeys, key) })
+	}
+
+	for name, value := range profiles {
+		profile, _ := value.(map[string]any)
+
+		if settings, ok := profile["settings"].(map[string]any); ok {
+			// Nothing is allowed when the provider is unknown
+			allowed := projectSettings[profileProvider(append(base, doc), name)]
+			remove(settings, []string{"profiles", name, "settings"}, func(key string) bool { return slices.Contains(allowed, key) })
+		}
+	}
+
+	slices.Sort(ignored)
+
+	return ignored
+}
+
+// profileProvider returns the provider of the named profile, or of the profile it extends,
+// as set by the last of docs.
+func profileProvider(docs []map[string]any, name string) string {
+	visited := map[string]bool{}
+
+	for name != "" && !visited[name] {
+		visited[name] = true
+
+		var provider, extends string
+
+		for _, doc := range docs {
+			profiles, _ := doc["profiles"].(map[string]any)
+			profile, _ := profiles[name].(map[string]any)
+
+			if p, ok := profile["provider"].(string); ok && p != "" {
+				provider = p
+			}
+			if e, ok := profile["extends"].(string); ok {
+				extends = e
+			}
+		}
+
+		if provider != "" {
+			return provider
+		}
+
+		name = extends
+	}
+
+	return ""
+}
+
+// projectConfigFile returns the .qai.json file in the working directory or the closest
+// parent directory that has one, like .env files are found. It returns "" when there is none.
+func (app *App) projectConfigFile() string {
+	dir, err := app.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	for {
+		if fp, ok := findConfigFile(dir, PROJECT_FILENAME); ok {
+			return fp
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// envLayer collects the QAI_* environment variables that name a config key. Values are
+// parsed as JSON when possible, like the values of "qai config set".
+func (app *App) envLayer() configLayer {
+	layer := configLayer{Name: LAYER_ENV, Keys: []string{}}
+
+	keys := map[string]bool{}
+	for key := range ConfigSchema()["properties"].(map[string]any) {
+		keys[key] = true
+	}
+
+	// The version belongs to a file, QAI_VERSION may well be used for something else
+	delete(keys, "version")
+
+	environ := app.Environ()
+	slices.Sort(environ)
+
+	doc := map[string]any{}
+	var names []string
+
+	for _, entry := range environ {
+		name, value, _ := strings.Cut(entry, "=")
+
+		if !strings.HasPrefix(name, ENV_PREFIX) {
+			continue
+		}
+
+		path := strings.Split(strings.ToLower(strings.TrimPrefix(name, ENV_PREFIX)), ENV_SEPARATOR)
+
+		// Other variables, such as QAI_SECRET_KEY
+		if !keys[path[0]] || slices.Contains(path, "") {
+			continue
+		}
+
+		if err := setPath(doc, path, parseValue(value)); err != nil {
+			layer.Error = fmt.Sprintf("%s: %v", name, err)
+			return layer
+		}
+
+		names = append(names, name)
+	}
+
+	layer.Source = strings.Join(names, ", ")
+	layer.Found = len(names) > 0
+	layer.Keys = leafKeys(doc, nil)
+	layer.doc = doc
+
+	return layer
+}
+
+// mergeLayers returns the config made of the layers that were found.
+func mergeLayers(layers []configLayer) (*Config, error) {
+	merged := jsonmap.NewJsonMap()
+
+	for _, layer := range layers {
+		if layer.Error != "" {
+			return nil, fmt.Errorf("error in the %s config: %s", layer.Name, layer.Error)
+		}
+
+		if layer.Found {
+			jsonmap.DeepAssign(merged, layer.doc)
+		}
+	}
+
+	config := NewConfig()
+
+	if err := roundTrip(merged, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// leafKeys returns the sorted dotted keys of the values in doc that are not objects.
+func leafKeys(doc map[string]any, path []string) []string {
+	keys := []string{}
+
+	for key, value := range doc {
+		child := append(path[:len(path):len(path)], key)
+
+		if m, ok := value.(map[string]any); ok && len(m) > 0 {
+			keys = append(keys, leafKeys(m, child)...)
+			continue
+		}
+
+		keys = append(keys, strings.Join(child, "."))
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+// exists reports whether the file of the layer exists, even when it can't be read.
+func (l configLayer) exists() bool {
+	return l.Found || l.Error != ""
+}
+
+func findLayer(layers []configLayer, name string) configLayer {
+	for _, layer := range layers {
+		if layer.Name == name {
+			return layer
+		}
+	}
+	return configLayer{Name: name}
+}
+
+func (app *App) runConfigSources(args []string) error {
+	fs := flag.NewFlagSet(APP_NAME+" config sources", flag.ContinueOnError)
+	fs.SetOutput(app.Stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return &usageError{err: err}
+	}
+
+	layers := app.configLayers()
+
+	if app.outputMode() == OUTPUT_JSON {
+		if err := app.writeJSON(layers); err != nil {
+			return err
+		}
+	} else {
+		tw := tabwriter.NewWriter(app.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "LAYER\tSTATUS\tKEYS\tSOURCE")
+
+		for _, layer := range layers {
+			status := "not found"
+
+			switch {
+			case layer.Error != "":
+				status = "invalid"
+			case layer.Found:
+				status = "applied"
+			case layer.Name == LAYER_ENV:
+				status = "not set"
+			}
+
+			source := layer.Source
+			if source == "" && layer.Name == LAYER_PROJECT {
+				source = "no " + PROJECT_FILENAME + " in the working directory or its parents"
+			} else if source == "" && layer.Name == LAYER_ENV {
+				source = ENV_PREFIX + "*"
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", layer.Name, status, len(layer.Keys), source)
+		}
+
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		if project := findLayer(layers, LAYER_PROJECT); len(project.Ignored) > 0 {
+			app.UI.Printf("Ignored keys of the project config: %s\n", strings.Join(project.Ignored, ", "))
+		}
+	}
+
+	for _, layer := range layers {
+		if layer.Error != "" {
+			return fmt.Errorf("error in the %s config: %s", layer.Name, layer.Error)
+		}
+	}
+
+	return nil
+}
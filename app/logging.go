@@ -0,0 +1,66 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/mcnull/qai/shared/logging"
+)
+
+// initLogger sets up the logger from --log-level, --debug and --log-file.
+// Without a log file, records are written to stderr.
+func (app *App) initLogger() error {
+	level, err := logging.ParseLevel(app.Flags.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	if app.Flags.Debug {
+		level = slog.LevelDebug
+	}
+
+	w := app.UI.Writer()
+
+	if app.Flags.LogFile != "" {
+		f, err := logging.OpenFile(app.Flags.LogFile)
+		if err != nil {
+			return fmt.Errorf("error opening log file: %w", err)
+		}
+		w = f
+		app.logFile = f
+	}
+
+	app.Logger = logging.New(w, level)
+
+	return nil
+}
+
+// logResult logs the outcome of a request.
+func (app *App) logResult(result *Result) {
+	attrs := []any{
+		"provider", result.Provider,
+		"profile", result.Profile,
+		"model", result.Model,
+		"first_token_ms", result.Timings.TimeToFirstTokenMs,
+		"total_ms", result.Timings.TotalMs,
+	}
+
+	if result.Usage != nil {
+		attrs = append(attrs, "prompt_tokens", result.Usage.PromptTokens, "completion_tokens", result.Usage.CompletionTokens)
+	}
+
+	if result.Error != nil {
+		app.Logger.Info("request failed", append(attrs, "error", result.Error.Message, "class", result.Error.Class)...)
+		return
+	}
+
+	app.Logger.Info("request finished", attrs...)
+}
+
+// closeLog closes the log file of --log-file, if any.
+func (app *App) closeLog() {
+	if app.logFile != nil {
+		app.logFile.Close()
+		app.logFile = nil
+	}
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// CONFIG_VERSION is the version of the config format written by this version of qai.
+// Raise it together with a new entry in configMigrations when the format changes.
+const CONFIG_VERSION = 1
+
+// configMigration upgrades a config document from the previous version to version.
+type configMigration struct {
+	version     int
+	description string
+	migrate     func(doc map[string]any) error
+}
+
+// configMigrations upgrade config documents one version at a time, in order. A config
+// without a version is version 0, as written by qai 0.5.2 and earlier.
+var configMigrations = []configMigration{
+	{
+		version:     1,
+		description: "add the version of the config format",
+		// Version 1 only adds fields, the version is set by migrateConfig
+		migrate: func(doc map[string]any) error { return nil },
+	},
+}
+
+// configVersion returns the version of the config document.
+func configVersion(doc map[string]any) (int, error) {
+	value, ok := doc["version"]
+	if !ok {
+		return 0, nil
+	}
+
+	var version int
+
+	switch v := value.(type) {
+	case json.Number:
+		i, err := v.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid config version %v", v)
+		}
+		version = int(i)
+	case float64:
+		version = int(v)
+		if float64(version) != v {
+			return 0, fmt.Errorf("invalid config version %v", v)
+		}
+	default:
+		return 0, fmt.Errorf("invalid config version %v", v)
+	}
+
+	if version < 0 {
+		return 0, fmt.Errorf("invalid config version %d", version)
+	}
+
+	return version, nil
+}
+
+// migrateConfig upgrades doc to CONFIG_VERSION and returns the version it had.
+func migrateConfig(doc map[string]any) (int, error) {
+	version, err := configVersion(doc)
+	if err != nil {
+		return 0, err
+	}
+
+	if version > CONFIG_VERSION {
+		return version, fmt.Errorf("the config has version %d, but this version of %s only supports up to version %d, please upgrade %s", version, APP_NAME, CONFIG_VERSION, APP_NAME)
+	}
+
+	for _, m := range configMigrations {
+		if m.version <= version {
+			continue
+		}
+
+		if err := m.migrate(doc); err != nil {
+			return version, fmt.Errorf("error migrating the config to version %d (%s): %w", m.version, m.description, err)
+		}
+
+		doc["version"] = m.version
+	}
+
+	return version, nil
+}
+
+// upgradeConfigFile migrates the config file at fp to CONFIG_VERSION. The original file is
+// kept next to it, as config.json.v0.bak for a config of version 0. The path of the
+// backup is empty when the file was already up to date.
+func upgradeConfigFile(fp string) (int, string, error) {
+	doc, file, err := readConfigDocument(fp)
+	if err != nil {
+		return 0, "", err
+	}
+
+	version, err := migrateConfig(doc)
+	if err != nil || version == CONFIG_VERSION {
+		return version, "", err
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", fp, version)
+
+	// The config may contain tokens
+	if err := os.WriteFile(backup, file.Bytes(), 0o600); err != nil {
+		return version, "", fmt.Errorf("error backing up config: %w", err)
+	}
+
+	if err := file.Update(doc); err != nil {
+		return version, "", fmt.Errorf("error updating config: %w", err)
+	}
+
+	if err := os.WriteFile(fp, file.Bytes(), 0o644); err != nil {
+		return version, "", fmt.Errorf("error saving config: %w", err)
+	}
+
+	return version, backup, nil
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mcnull/qai/shared/configfile"
+)
+
+// The fixtures in testdata/config are configs as written for each version of the format:
+// v0.json is the config created by qai 0.5.2, v0-edited.json one edited by hand for it.
+func TestConfigMigrations(t *testing.T) {
+	tests := []struct {
+		fixture string
+		version int
+	}{
+		{"v0.json", 0},
+		{"v0-edited.json", 0},
+		{"v1.yaml", 1},
+	}
+
+	for _, tt := range tests {
+		original, err := os.ReadFile(filepath.Join("testdata", "config", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The config as it was read before migrations existed
+		f, err := configfile.Parse(original, configfile.FormatOf(tt.fixture))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.fixture, err)
+		}
+
+		want := NewConfig()
+		if err := f.Decode(want); err != nil {
+			t.Fatalf("%s: %v", tt.fixture, err)
+		}
+		want.Version = CONFIG_VERSION
+
+		home := t.TempDir()
+		file := filepath.Join(home, ".config", "qai", "config"+filepath.Ext(tt.fixture))
+
+		os.MkdirAll(filepath.Dir(file), 0o755)
+		os.WriteFile(file, original, 0o644)
+
+		run := runApp(t, home, "", nil, "config", "show")
+		if run.code != EXIT_OK {
+			t.Fatalf("%s: exit code %d, stderr: %s", tt.fixture, run.code, run.stderr)
+		}
+
+		backup := file + ".v0.bak"
+
+		if tt.version < CONFIG_VERSION {
+			if !strings.Contains(run.stderr, "Upgraded "+file) {
+				t.Fatalf("%s: expected the upgrade to be reported, got %q", tt.fixture, run.stderr)
+			}
+
+			if data, err := os.ReadFile(backup); err != nil || string(data) != string(original) {
+				t.Fatalf("%s: expected the original in %s (%v)", tt.fixture, backup, err)
+			}
+		} else if _, err := os.Stat(backup); !os.IsNotExist(err) {
+			t.Fatalf("%s: expected no backup of an up to date config", tt.fixture)
+		}
+
+		got, err := LoadConfig(file)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.fixture, err)
+		}
+		got.file = nil
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: the migrated config differs\nwant %+v\ngot  %+v", tt.fixture, want, got)
+		}
+
+		// The upgraded file is left alone
+		run = runApp(t, home, "", nil, "config", "show")
+		if run.code != EXIT_OK || strings.Contains(run.stderr, "Upgraded") {
+			t.Fatalf("%s: unexpected second upgrade %d %q", tt.fixture, run.code, run.stderr)
+		}
+	}
+}
+
+func TestConfigFromTheFuture(t *testing.T) {
+	home := newHome(t, `{"version": 99, "profiles": {"default": {"provider": "mock"}}}`)
+
+	run := runApp(t, home, "", nil, "hi")
+	if run.code != EXIT_ERROR || !strings.Contains(run.stderr, "please upgrade qai") {
+		t.Fatalf("expected a newer config to be rejected, got %d %q", run.code, run.stderr)
+	}
+}
+
+func TestMigrateConfig(t *testing.T) {
+	doc := map[string]any{"profile": "default"}
+
+	version, err := migrateConfig(doc)
+	if err != nil || version != 0 || doc["version"] != CONFIG_VERSION {
+		t.Fatalf("unexpected migration %d %v %v", version, err, doc)
+	}
+
+	for _, invalid := range []any{"1", -1, 1.5, true} {
+		if _, err := migrateConfig(map[string]any{"version": invalid}); err == nil {
+			t.Fatalf("expected an error for version %v", invalid)
+		}
+	}
+}
+
+// A migration that changes the data is run after the earlier ones, and the backup holds
+// the original file.
+func TestConfigMigrationChain(t *testing.T) {
+	defer func(migrations []configMigration) { configMigrations = migrations }(configMigrations)
+
+	configMigrations = append(slices.Clone(configMigrations), configMigration{
+		version:     CONFIG_VERSION + 1,
+		description: "rename the github provider to copilot",
+		migrate: func(doc map[string]any) error {
+			providers, _ := doc["providers"].(map[string]any)
+			if github, ok := providers["github"]; ok {
+				providers["copilot"] = github
+				delete(providers, "github")
+			}
+			return nil
+		},
+	})
+
+	original, err := os.ReadFile(filepath.Join("testdata", "config", "v0-edited.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), CONFIG_FILENAME)
+	os.WriteFile(file, original, 0o644)
+
+	version, backup, err := upgradeConfigFile(file)
+	if err != nil || version != 0 || backup != file+".v0.bak" {
+		t.Fatalf("unexpected upgrade %d %q %v", version, backup, err)
+	}
+
+	if data, err := os.ReadFile(backup); err != nil || string(data) != string(original) {
+		t.Fatalf("expected the original in %s (%v)", backup, err)
+	}
+
+	doc, _, err := readConfigDocument(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	providers := doc["providers"].(map[string]any)
+	copilot, _ := providers["copilot"].(map[string]any)
+
+	if _, ok := providers["github"]; ok || copilot["token"] != "gho_secret" {
+		t.Fatalf("expected the github provider to be renamed, got %v", providers)
+	}
+
+	if v, _ := configVersion(doc); v != CONFIG_VERSION+1 {
+		t.Fatalf("expected version %d, got %v", CONFIG_VERSION+1, doc["version"])
+	}
+
+	// The rest of the file is kept as it was
+	data, _ := os.ReadFile(file)
+	if !strings.Contains(string(data), `"model": "qwen2.5-coder"`) || !strings.Contains(string(data), `"seed": 7`) {
+		t.Fatalf("unexpected upgraded config\n%s", data)
+	}
+}
@@ -0,0 +1,229 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/mcnull/qai/shared/markdown"
+	"github.com/mcnull/qai/shared/provider"
+	"github.com/mcnull/qai/shared/terminal"
+)
+
+const (
+	OUTPUT_RAW      = "raw"
+	OUTPUT_MARKDOWN = "markdown"
+	OUTPUT_JSON     = "json"
+	OUTPUT_NDJSON   = "ndjson"
+)
+
+// output writes the response to the user in one of the output formats.
+type output interface {
+	// Write handles a chunk of the response.
+	Write(chunk provider.GenerateResponse) error
+	// Close is called once when the response is complete, failed or was interrupted.
+	Close(result *Result) error
+	// Streams reports whether the output benefits from a streamed response.
+	Streams() bool
+}
+
+// outputMode returns the selected output mode. Without --output the answer is rendered as
+// markdown when colors are enabled, except for answers constrained by a schema which are
+// written as-is.
+func (app *App) outputMode() string {
+	if app.Flags.Output != "" {
+		return app.Flags.Output
+	}
+
+	if app.Flags.Schema != "" {
+		return OUTPUT_RAW
+	}
+
+	if terminal.ColorEnabled(app.Flags.Color, app.Stdout, app.getenv) {
+		return OUTPUT_MARKDOWN
+	}
+
+	return OUTPUT_RAW
+}
+
+// markdownStyle returns the --style value, or the dark or light style matching the
+// background of the terminal. Without colors the markdown is rendered as plain text.
+func (app *App) markdownStyle(w io.Writer) string {
+	if !terminal.ColorEnabled(app.Flags.Color, w, app.getenv) {
+		return styles.NoTTYStyle
+	}
+
+	if app.Flags.Style != "" {
+		return app.Flags.Style
+	}
+
+	if terminal.HasDarkBackground() {
+		return styles.DarkStyle
+	}
+
+	return styles.LightStyle
+}
+
+func (app *App) newOutput(mode string, w io.Writer) (output, error) {
+	switch mode {
+	case OUTPUT_RAW:
+		return &rawOutput{w: w}, nil
+	case OUTPUT_MARKDOWN:
+		r, err := markdown.NewMarkdownRenderer(app.markdownStyle(w), terminal.Width(w))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize markdown renderer: %w", err)
+		}
+		return &markdownOutput{w: w, renderer: r}, nil
+	case OUTPUT_JSON:
+		return &jsonOutput{w: w}, nil
+	case OUTPUT_NDJSON:
+		return &ndjsonOutput{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected one of: %s, %s, %s, %s",
+			mode, OUTPUT_RAW, OUTPUT_MARKDOWN, OUTPUT_JSON, OUTPUT_NDJSON)
+	}
+}
+
+// rawOutput writes the response text as it arrives.
+type rawOutput struct {
+	w       io.Writer
+	written bool
+}
+
+func (o *rawOutput) Write(chunk provider.GenerateResponse) error {
+	if chunk.Response == "" {
+		return nil
+	}
+
+	o.written = true
+	_, err := io.WriteString(o.w, chunk.Response)
+	return err
+}
+
+func (o *rawOutput) Close(result *Result) error {
+	// Ends the line of the response, nothing is written for an empty response
+	if !o.written {
+		return nil
+	}
+
+	_, err := fmt.Fprintln(o.w)
+	return err
+}
+
+func (o *rawOutput) Streams() bool { return true }
+
+// markdownOutput renders the response as markdown for the terminal.
+type markdownOutput struct {
+	w        io.Writer
+	renderer *markdown.MarkdownRenderer
+}
+
+func (o *markdownOutput) Write(chunk provider.GenerateResponse) error {
+	rendered, err := o.renderer.Render(chunk.Response, chunk.Done)
+	if err != nil {
+		return fmt.Errorf("error rendering markdown: %w", err)
+	}
+
+	if rendered != "" {
+		_, err = io.WriteString(o.w, rendered)
+	}
+
+	return err
+}
+
+func (o *markdownOutput) Close(result *Result) error {
+	// Flush any remaining content
+	remaining, err := o.renderer.Render("", true)
+	if err != nil {
+		return fmt.Errorf("error rendering markdown: %w", err)
+	}
+
+	remaining = strings.Trim(remaining, "\n")
+	if remaining != "" {
+		_, err = fmt.Fprintln(o.w, remaining)
+	}
+
+	return err
+}
+
+func (o *markdownOutput) Streams() bool { return false }
+
+// jsonOutput writes a single JSON object describing the result.
+type jsonOutput struct {
+	w io.Writer
+}
+
+func (o *jsonOutput) Write(chunk provider.GenerateResponse) error {
+	return nil
+}
+
+func (o *jsonOutput) Close(result *Result) error {
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+func (o *jsonOutput) Streams() bool { return false }
+
+// ndjsonOutput writes one JSON event per line for every chunk, followed by a final
+// "done" or "error" event carrying the result. With a profile, as for --compare, every
+// event names it.
+type ndjsonOutput struct {
+	enc     *json.Encoder
+	profile string
+}
+
+type outputEvent struct {
+	Type    string `json:"type"`
+	Profile string `json:"profile,omitempty"`
+	Text    string `json:"text,omitempty"`
+	*Result
+}
+
+func (o *ndjsonOutput) Write(chunk provider.GenerateResponse) error {
+	if chunk.Response == "" {
+		return nil
+	}
+	return o.enc.Encode(outputEvent{Type: "chunk", Profile: o.profile, Text: chunk.Response})
+}
+
+func (o *ndjsonOutput) Close(result *Result) error {
+	// The profile of the event hides the one of the result
+	event := outputEvent{Type: "done", Profile: result.Profile, Result: result}
+	if result.Error != nil {
+		event.Type = "error"
+	}
+	return o.enc.Encode(event)
+}
+
+func (o *ndjsonOutput) Streams() bool { return true }
+
+// bufferedOutput holds back the response until it is complete, then writes it
+// to the wrapped output as a single chunk.
+type bufferedOutput struct {
+	output
+}
+
+func (o *bufferedOutput) Write(chunk provider.GenerateResponse) error {
+	return nil
+}
+
+func (o *bufferedOutput) Close(result *Result) error {
+	if result.Error == nil && result.Response != "" {
+		err := o.output.Write(provider.GenerateResponse{
+			Response: result.Response,
+			Done:     true,
+			Model:    result.Model,
+			Usage:    result.Usage,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return o.output.Close(result)
+}
+
+func (o *bufferedOutput) Streams() bool { return false }
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+// profileSummary is a line of "qai profiles".
+type profileSummary struct {
+	Name     string   `json:"name"`
+	Provider string   `json:"provider"`
+	Model    string   `json:"model"`
+	Extends  string   `json:"extends,omitempty"`
+	Fallback []string `json:"fallback,omitempty"`
+	Default  bool     `json:"default"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// summarizeProfile resolves the named profile and the model it uses.
+func (app *App) summarizeProfile(name string) profileSummary {
+	summary := profileSummary{
+		Name:    name,
+		Extends: app.Config.Profiles[name].Extends,
+		Default: name == app.Flags.Profile,
+	}
+
+	profile, err := app.Config.GetProfile(name)
+	if err != nil {
+		summary.Error = err.Error()
+		return summary
+	}
+
+	summary.Provider = profile.Provider
+	summary.Fallback = profile.Fallback
+
+	if _, err := app.Config.FallbackChain(name); err != nil {
+		summary.Error = err.Error()
+		return summary
+	}
+
+	// A profile that only lists fallback profiles has no provider of its own
+	if profile.Provider == "" {
+		return summary
+	}
+
+	factories, ok := providerFactories[profile.Provider]
+	if !ok {
+		summary.Error = unknownProviderMessage(profile.Provider)
+		return summary
+	}
+
+	config, err := provider.InitConfig(app.Config.Providers.Get(profile.Provider), factories.config, profile.Settings)
+	if err != nil {
+		summary.Error = err.Error()
+		return summary
+	}
+
+	var settings map[string]any
+	if err := roundTrip(config, &settings); err == nil {
+		summary.Model, _ = settings["model"].(string)
+	}
+
+	return summary
+}
+
+func (app *App) runProfiles(args []string) error {
+	fs := flag.NewFlagSet(APP_NAME+" profiles", flag.ContinueOnError)
+	fs.SetOutput(app.Stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return &usageError{err: err}
+	}
+
+	var summaries []profileSummary
+	for _, name := range app.Config.ProfileNames() {
+		summaries = append(summaries, app.summarizeProfile(name))
+	}
+
+	if app.outputMode() == OUTPUT_JSON {
+		return app.writeJSON(summaries)
+	}
+
+	tw := tabwriter.NewWriter(app.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  NAME\tPROVIDER\tMODEL\tEXTENDS\tFALLBACK")
+
+	for _, s := range summaries {
+		marker := " "
+		if s.Default {
+			marker = "*"
+		}
+
+		if s.Error != "" {
+			fmt.Fprintf(tw, "%s %s\t-\t-\t%s\t%s\n", marker, s.Name, orDash(s.Extends), orDash(strings.Join(s.Fallback, ", ")))
+			continue
+		}
+
+		fmt.Fprintf(tw, "%s %s\t%s\t%s\t%s\t%s\n", marker, s.Name, orDash(s.Provider), orDash(s.Model), orDash(s.Extends), orDash(strings.Join(s.Fallback, ", ")))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Broken profiles are listed, the problems are explained below the table
+	for _, s := range summaries {
+		if s.Error != "" {
+			app.UI.Printf("Error in profile %q: %s\n", s.Name, s.Error)
+		}
+	}
+
+	return nil
+}
+
+// orDash returns s, or "-" for an empty column.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testProfilesConfig = `{
+  "profile": "precise",
+  "profiles": {
+    "base": { "provider": "mock", "timeout": "1m", "settings": { "response": "from base", "model": "base-model", "chunk_size": 4 } },
+    "precise": { "extends": "base", "settings": { "model": "precise-model" } },
+    "echo": { "extends": "precise", "settings": { "response": "" } },
+    "loop-a": { "extends": "loop-b" },
+    "loop-b": { "extends": "loop-a" },
+    "orphan": { "extends": "missing" }
+  }
+}`
+
+func TestGetProfileExtends(t *testing.T) {
+	config := NewConfig()
+	if err := json.Unmarshal([]byte(testProfilesConfig), config); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := config.GetProfile("echo")
+	if err != nil {
+		t.Fatalf("GetProfile failed: %v", err)
+	}
+
+	if profile.Provider != "mock" || profile.Timeout == nil || profile.Extends != "precise" {
+		t.Fatalf("expected the provider and timeout of the base, got %+v", profile)
+	}
+
+	want := map[string]any{"response": "", "model": "precise-model", "chunk_size": float64(4)}
+	for key, value := range want {
+		if profile.Settings[key] != value {
+			t.Fatalf("setting %s: expected %v, got %v", key, value, profile.Settings[key])
+		}
+	}
+
+	// The base profile is not changed by the merge
+	if config.Profiles["base"].Settings["model"] != "base-model" {
+		t.Fatalf("the base profile was changed: %v", config.Profiles["base"].Settings)
+	}
+
+	if _, err := config.GetProfile("loop-a"); err == nil || !strings.Contains(err.Error(), "loop-a -> loop-b -> loop-a") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+
+	if _, err := config.GetProfile("orphan"); err == nil || !strings.Contains(err.Error(), `profile "orphan" extends an unknown profile`) {
+		t.Fatalf("expected an unknown base error, got %v", err)
+	}
+
+	_, err = config.GetProfile("nope")
+
+	var nErr *ProfileNotFoundError
+	if !errors.As(err, &nErr) || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a ProfileNotFoundError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "available profiles: base, default, echo, loop-a, loop-b, orphan, precise") {
+		t.Fatalf("expected the available profiles, got %v", err)
+	}
+}
+
+func TestProfilesCommand(t *testing.T) {
+	home := newHome(t, testProfilesConfig)
+
+	run := runApp(t, home, "", nil, "profiles")
+	if run.code != EXIT_OK {
+		t.Fatalf("exit code %d, stderr: %s", run.code, run.stderr)
+	}
+
+	for _, line := range []string{
+		"* precise  mock      precise-model  base",
+		"  base     mock      base-model     -",
+		"  orphan   -         -              missing",
+	} {
+		if !strings.Contains(run.stdout, line) {
+			t.Fatalf("expected %q in\n%s", line, run.stdout)
+		}
+	}
+
+	if !strings.Contains(run.stderr, `Error in profile "loop-a"`) {
+		t.Fatalf("expected the cycle to be reported, got %q", run.stderr)
+	}
+
+	run = runApp(t, home, "", nil, "-output", "json", "-profile", "base", "profiles")
+
+	var summaries []profileSummary
+	if err := json.Unmarshal([]byte(run.stdout), &summaries); err != nil {
+		t.Fatalf("invalid JSON %q: %v", run.stdout, err)
+	}
+
+	if summaries[0].Name != "base" || !summaries[0].Default {
+		t.Fatalf("expected base to be the default, got %+v", summaries[0])
+	}
+
+	// A prompt uses the merged settings
+	run = runApp(t, home, "", nil, "-output", "raw", "hi")
+	if run.code != EXIT_OK || run.stdout != "from base\n" {
+		t.Fatalf("unexpected answer %d %q %q", run.code, run.stdout, run.stderr)
+	}
+
+	run = runApp(t, home, "", nil, "-profile", "nope", "hi")
+	if run.code != EXIT_ERROR || !strings.Contains(run.stderr, "available profiles: base, default") {
+		t.Fatalf("expected the available profiles, got %d %q", run.code, run.stderr)
+	}
+}
+
+func TestValidateProfileExtends(t *testing.T) {
+	home := newHome(t, testProfilesConfig)
+
+	run := runApp(t, home, "", nil, "config", "validate")
+
+	for _, want := range []string{
+		"profiles.loop-a.extends: profiles extend each other in a cycle",
+		`profiles.orphan.extends: profile "orphan" extends an unknown profile`,
+	} {
+		if !strings.Contains(run.stdout, want) {
+			t.Fatalf("expected %q in %q", want, run.stdout)
+		}
+	}
+
+	if strings.Contains(run.stdout, "profiles.echo") || strings.Contains(run.stdout, "profiles.precise") {
+		t.Fatalf("expected the valid profiles to pass, got %q", run.stdout)
+	}
+}
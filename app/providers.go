@@ -0,0 +1,59 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mcnull/qai/providers/github"
+	"github.com/mcnull/qai/providers/mock"
+	"github.com/mcnull/qai/providers/ollama"
+	"github.com/mcnull/qai/shared/provider"
+)
+
+// providerFactories creates the config and the provider for every supported provider name.
+var providerFactories = map[string]struct {
+	config   provider.ConfigFactory
+	provider provider.ProviderFactory
+}{
+	ollama.PROVIDER_NAME: {ollama.NewConfig, ollama.NewOllamaProvider},
+	github.PROVIDER_NAME: {github.NewConfig, github.NewGitHubProvider},
+	mock.PROVIDER_NAME:   {mock.NewConfig, mock.NewMockProvider},
+}
+
+// projectSettings are the provider settings a project config may set. Endpoints,
+// credentials and file paths, such as the responses file of mock, are only read from the
+// user config.
+var projectSettings = map[string][]string{
+	ollama.PROVIDER_NAME: {"model", "seed"},
+	github.PROVIDER_NAME: {"model"},
+	mock.PROVIDER_NAME:   {"model", "response", "chunk_size", "delay", "error_at", "error", "error_message"},
+}
+
+// providerNames returns the supported provider names in alphabetical order.
+func providerNames() []string {
+	names := make([]string, 0, len(providerFactories))
+	for name := range providerFactories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Get returns the config of the named provider from the "providers" section.
+func (c *ProvidersConfig) Get(name string) provider.IConfig {
+	switch name {
+	case ollama.PROVIDER_NAME:
+		return c.Ollama
+	case github.PROVIDER_NAME:
+		return c.GitHub
+	case mock.PROVIDER_NAME:
+		return c.Mock
+	default:
+		return nil
+	}
+}
+
+func unknownProviderMessage(name string) string {
+	return fmt.Sprintf("unknown provider %q, expected one of: %s", name, strings.Join(providerNames(), ", "))
+}
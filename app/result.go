@@ -0,0 +1,109 @@
+package app
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+// Result describes a completed (or failed) request in a provider independent way.
+type Result struct {
+	Provider string          `json:"provider"`
+	Profile  string          `json:"profile"`
+	Model    string          `json:"model,omitempty"`
+	Response string          `json:"response"`
+	Usage    *provider.Usage `json:"usage,omitempty"`
+	Timings  Timings         `json:"timings"`
+	Error    *ErrorInfo      `json:"error,omitempty"`
+
+	text strings.Builder
+}
+
+// Timings holds the wall clock timings of a request as measured by qai.
+type Timings struct {
+	Start              time.Time `json:"start"`
+	TimeToFirstTokenMs int64     `json:"time_to_first_token_ms"`
+	TotalMs            int64     `json:"total_ms"`
+
+	firstToken time.Time
+}
+
+// ErrorInfo is the JSON representation of an error.
+type ErrorInfo struct {
+	Message string `json:"message"`
+	Class   string `json:"class,omitempty"`
+	Status  int    `json:"status,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+func newResult(providerName, profile, model string, start time.Time) *Result {
+	return &Result{
+		Provider: providerName,
+		Profile:  profile,
+		Model:    model,
+		Timings:  Timings{Start: start},
+	}
+}
+
+// add records a chunk of the response.
+func (r *Result) add(resp provider.GenerateResponse, now time.Time) {
+	if r.Timings.firstToken.IsZero() {
+		r.Timings.firstToken = now
+		r.Timings.TimeToFirstTokenMs = now.Sub(r.Timings.Start).Milliseconds()
+	}
+
+	r.text.WriteString(resp.Response)
+
+	if resp.Model != "" {
+		r.Model = resp.Model
+	}
+
+	if resp.Usage != nil {
+		r.Usage = resp.Usage
+	}
+}
+
+// started reports whether any text of the response was received. Empty chunks, such as
+// keep-alives or a role delta, write nothing, so the request can still fall back.
+func (r *Result) started() bool {
+	return r.text.Len() > 0
+}
+
+// reset discards the response received so far, so the request can be sent again.
+func (r *Result) reset() {
+	r.text.Reset()
+	r.Usage = nil
+	r.Timings.firstToken = time.Time{}
+	r.Timings.TimeToFirstTokenMs = 0
+}
+
+// setResponse replaces the response received so far.
+func (r *Result) setResponse(text string) {
+	r.text.Reset()
+	r.text.WriteString(text)
+}
+
+// finish completes the result, err is the reason the request failed if any.
+func (r *Result) finish(err error, now time.Time) {
+	r.Response = r.text.String()
+	r.Timings.TotalMs = now.Sub(r.Timings.Start).Milliseconds()
+
+	if err != nil {
+		r.Error = newErrorInfo(err)
+	}
+}
+
+func newErrorInfo(err error) *ErrorInfo {
+	info := &ErrorInfo{Message: err.Error()}
+
+	if pErr, ok := provider.AsError(err); ok {
+		info.Class = pErr.Class.String()
+		info.Status = pErr.Status
+		info.Code = pErr.Code
+		info.Hint = pErr.Hint
+	}
+
+	return info
+}
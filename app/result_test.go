@@ -0,0 +1,45 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+// A request sent again is timed from scratch.
+func TestResultReset(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	result := newResult("mock", "default", "", start)
+	result.add(provider.GenerateResponse{Response: "no", Usage: &provider.Usage{CompletionTokens: 1}}, start.Add(time.Second))
+	result.reset()
+
+	if result.started() || result.Usage != nil || result.Timings.TimeToFirstTokenMs != 0 || result.text.Len() != 0 {
+		t.Fatalf("expected an empty result, got %+v", result)
+	}
+
+	result.add(provider.GenerateResponse{Response: "yes"}, start.Add(3*time.Second))
+	result.finish(nil, start.Add(4*time.Second))
+
+	if result.Response != "yes" || result.Timings.TimeToFirstTokenMs != 3000 || result.Timings.TotalMs != 4000 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestResultStarted(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	result := newResult("github", "copilot", "", start)
+	result.add(provider.GenerateResponse{Model: "gpt-4o"}, start.Add(time.Second))
+
+	if result.started() {
+		t.Fatal("an empty chunk must not start the answer")
+	}
+
+	result.add(provider.GenerateResponse{Response: "ls"}, start.Add(2*time.Second))
+
+	if !result.started() {
+		t.Fatal("expected the answer to be started")
+	}
+}
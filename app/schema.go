@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mcnull/qai/shared/markdown"
+	"github.com/mcnull/qai/shared/provider"
+	"github.com/mcnull/qai/shared/schema"
+)
+
+// loadSchema loads the JSON Schema given with --schema, nil when not set.
+func (app *App) loadSchema() (*schema.Schema, error) {
+	if app.Flags.Schema == "" {
+		return nil, nil
+	}
+
+	sch, err := schema.Load(app.Flags.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("error loading schema %s: %w", app.Flags.Schema, err)
+	}
+
+	return sch, nil
+}
+
+// schemaInstruction is appended to the system prompt for providers that don't enforce the
+// schema themselves, see provider.EnforcesSchema.
+func schemaInstruction(sch *schema.Schema) string {
+	return fmt.Sprintf("\nRespond only with a JSON document that conforms to the following JSON Schema, without any explanation or formatting:\n%s", sch.Raw)
+}
+
+// validateAnswer checks the answer in result against the schema. When it doesn't
+// conform, the request is sent once more together with the validation error.
+func (app *App) validateAnswer(ctx context.Context, sch *schema.Schema, request provider.GenerateRequest, out output, result *Result, firstTokenTimeout time.Duration) error {
+
+	answer := markdown.ExtractCode(result.text.String())
+
+	verr := sch.Validate(answer)
+	if verr == nil {
+		result.setResponse(answer)
+		return nil
+	}
+
+	retry := request
+	retry.Prompt = fmt.Sprintf("%s\n\nYour previous answer was:\n%s\n\nIt was rejected because: %v\nAnswer again with only a JSON document that conforms to the schema.",
+		request.Prompt, answer, verr)
+
+	result.reset()
+
+	if err := app.generate(ctx, retry, out, result, firstTokenTimeout); err != nil {
+		return err
+	}
+
+	answer = markdown.ExtractCode(result.text.String())
+
+	if err := sch.Validate(answer); err != nil {
+		result.setResponse(answer)
+		return &provider.Error{
+			Provider: result.Provider,
+			Class:    provider.ErrorClassRequest,
+			Message:  err.Error(),
+			Hint:     "the model did not produce a valid answer twice, try a more capable model or simplify the schema",
+		}
+	}
+
+	result.setResponse(answer)
+	return nil
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mcnull/qai/shared/provider"
+	"github.com/mcnull/qai/shared/schema"
+)
+
+// schemaProvider records the requests it gets and answers with a JSON document.
+type schemaProvider struct {
+	enforces bool
+	requests []provider.GenerateRequest
+}
+
+func (p *schemaProvider) GetName() string      { return "schema" }
+func (p *schemaProvider) Init() error          { return nil }
+func (p *schemaProvider) EnforcesSchema() bool { return p.enforces }
+
+func (p *schemaProvider) Generate(ctx context.Context, request provider.GenerateRequest) (<-chan provider.GenerateResponse, <-chan error) {
+	p.requests = append(p.requests, request)
+
+	responseChan := make(chan provider.GenerateResponse)
+	errorChan := make(chan error)
+
+	go func() {
+		defer close(responseChan)
+		defer close(errorChan)
+
+		responseChan <- provider.GenerateResponse{Response: `{"a": 1}`, Done: true}
+	}()
+
+	return responseChan, errorChan
+}
+
+// The schema is only described in the system prompt for providers that don't enforce it.
+func TestSchemaInstruction(t *testing.T) {
+	sch, err := schema.Parse([]byte(`{"type": "object", "required": ["a"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, enforces := range []bool{false, true} {
+		p := &schemaProvider{enforces: enforces}
+
+		app := NewApp().WithIO(strings.NewReader(""), io.Discard, io.Discard)
+		app.Flags.Output = OUTPUT_JSON
+		app.Provider = p
+
+		request := provider.GenerateRequest{System: "Be brief.", Prompt: "a", Schema: sch.Raw}
+		result := newResult(p.GetName(), "test", "", app.Now())
+
+		if err := app.ask(context.Background(), request, sch, &bufferedOutput{output: &jsonOutput{w: io.Discard}}, result); err != nil {
+			t.Fatalf("enforces %v: %v", enforces, err)
+		}
+
+		if len(p.requests) != 1 {
+			t.Fatalf("enforces %v: expected one request, got %d", enforces, len(p.requests))
+		}
+
+		system := p.requests[0].System
+		if instructed := strings.Contains(system, "JSON Schema"); instructed == enforces {
+			t.Fatalf("enforces %v: unexpected system prompt %q", enforces, system)
+		}
+		if !strings.HasPrefix(system, "Be brief.") {
+			t.Fatalf("enforces %v: expected the system prompt to be kept, got %q", enforces, system)
+		}
+	}
+}
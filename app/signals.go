@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+// ErrCancelled is the cause of the request context when the user interrupts qai.
+var ErrCancelled = &provider.Error{
+	Class:   provider.ErrorClassCancelled,
+	Message: "cancelled",
+}
+
+// notifyContext returns a context that is cancelled with ErrCancelled on the first
+// SIGINT or SIGTERM. A second signal makes Main return EXIT_CANCELLED without waiting
+// for the request to stop, see forceExit.
+// The returned stop function restores the default signal behavior.
+func (app *App) notifyContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+
+	sigs, release := app.signals, func() {}
+
+	if sigs == nil {
+		c := make(chan os.Signal, 2)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		sigs, release = c, func() { signal.Stop(c) }
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer release()
+
+		select {
+		case <-sigs:
+			cancel(ErrCancelled)
+		case <-done:
+			return
+		}
+
+		select {
+		case <-sigs:
+			app.forceExit()
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			cancel(nil)
+		})
+	}
+
+	return ctx, stop
+}
+
+// forceExit makes Main return right away, when the user insists on quitting.
+func (app *App) forceExit() {
+	app.forceOnce.Do(func() { close(app.forced) })
+}
+
+// isCancelled reports whether ctx was cancelled by the user.
+func isCancelled(ctx context.Context) bool {
+	return context.Cause(ctx) == ErrCancelled
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSignalsConfig = `{
+  "profile": "slow",
+  "profiles": {
+    "slow": { "provider": "mock", "settings": { "response": "one two three", "chunk_size": 4, "delay": "50ms" } }
+  }
+}`
+
+// An interrupt stops the request, the partial answer is kept.
+func TestInterrupt(t *testing.T) {
+	home := newHome(t, testSignalsConfig)
+
+	signals := make(chan os.Signal, 1)
+	app := newTestApp(home, "", nil)
+	app.WithSignals(signals)
+
+	// After the first chunk, before the second
+	time.AfterFunc(75*time.Millisecond, func() { signals <- os.Interrupt })
+
+	run := app.run("-output", "raw", "hi")
+
+	if run.code != EXIT_CANCELLED {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", EXIT_CANCELLED, run.code, run.stderr)
+	}
+	if run.stdout != "one \n" {
+		t.Fatalf("expected the partial answer, got %q", run.stdout)
+	}
+	if !strings.Contains(run.stderr, "[cancelled]") || strings.Contains(run.stderr, "Error") {
+		t.Fatalf("expected only the cancelled marker, got %q", run.stderr)
+	}
+}
+
+func TestNotifyContext(t *testing.T) {
+	signals := make(chan os.Signal, 1)
+	app := NewApp().WithSignals(signals)
+
+	ctx, stop := app.notifyContext(context.Background())
+	defer stop()
+
+	signals <- os.Interrupt
+	<-ctx.Done()
+
+	if context.Cause(ctx) != ErrCancelled || !isCancelled(ctx) {
+		t.Fatalf("expected ErrCancelled, got %v", context.Cause(ctx))
+	}
+
+	select {
+	case <-app.forced:
+		t.Fatal("a single interrupt forced the exit")
+	default:
+	}
+
+	// The second interrupt doesn't wait for the request
+	signals <- os.Interrupt
+
+	select {
+	case <-app.forced:
+	case <-time.After(time.Second):
+		t.Fatal("expected the second interrupt to force the exit")
+	}
+
+	// Not cancelled by the user
+	ctx, stop = app.notifyContext(context.Background())
+	stop()
+
+	if isCancelled(ctx) {
+		t.Fatal("stopping the context is not a cancellation")
+	}
+}
+
+// A second interrupt makes Main return, even when qai is stuck.
+func TestForcedExit(t *testing.T) {
+	home := newHome(t, testSignalsConfig)
+
+	// The prompt is never read to the end
+	stdin, w := io.Pipe()
+	defer w.Close()
+
+	app := newTestApp(home, "", nil)
+	app.Stdin = stdin
+
+	time.AfterFunc(20*time.Millisecond, app.forceExit)
+
+	if run := app.run("-"); run.code != EXIT_CANCELLED {
+		t.Fatalf("expected exit code %d, got %d", EXIT_CANCELLED, run.code)
+	}
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// formatStats returns a one line summary of the token usage and timings of result.
+func formatStats(result *Result) string {
+	var parts []string
+
+	usage := result.Usage
+	total := time.Duration(result.Timings.TotalMs) * time.Millisecond
+	ttft := time.Duration(result.Timings.TimeToFirstTokenMs) * time.Millisecond
+
+	if usage != nil {
+		parts = append(parts, fmt.Sprintf("tokens: %d in, %d out", usage.PromptTokens, usage.CompletionTokens))
+
+		tps := usage.TokensPerSecond()
+
+		// Fall back to the time spent receiving the response
+		if tps == 0 && usage.CompletionTokens > 0 && total > ttft {
+			tps = float64(usage.CompletionTokens) / (total - ttft).Seconds()
+		}
+
+		if tps > 0 {
+			parts = append(parts, fmt.Sprintf("%.1f tokens/s", tps))
+		}
+	} else {
+		parts = append(parts, "tokens: n/a")
+	}
+
+	parts = append(parts, fmt.Sprintf("first token: %s", formatDuration(ttft)))
+
+	if usage != nil && usage.LoadDuration > 0 {
+		parts = append(parts, fmt.Sprintf("load: %s", formatDuration(usage.LoadDuration)))
+	}
+
+	parts = append(parts, fmt.Sprintf("total: %s", formatDuration(total)))
+
+	return strings.Join(parts, " | ")
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
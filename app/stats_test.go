@@ -0,0 +1,46 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+func TestFormatStats(t *testing.T) {
+	result := &Result{
+		Usage: &provider.Usage{
+			PromptTokens:     30,
+			CompletionTokens: 120,
+			EvalDuration:     2 * time.Second,
+			LoadDuration:     1500 * time.Millisecond,
+		},
+		Timings: Timings{TimeToFirstTokenMs: 250, TotalMs: 3400},
+	}
+
+	got := formatStats(result)
+	want := "tokens: 30 in, 120 out | 60.0 tokens/s | first token: 250ms | load: 1.5s | total: 3.4s"
+
+	if got != want {
+		t.Fatalf("formatStats() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatStatsWithoutProviderTimings(t *testing.T) {
+	result := &Result{
+		Usage:   &provider.Usage{PromptTokens: 5, CompletionTokens: 20},
+		Timings: Timings{TimeToFirstTokenMs: 1000, TotalMs: 3000},
+	}
+
+	got := formatStats(result)
+	want := "tokens: 5 in, 20 out | 10.0 tokens/s | first token: 1s | total: 3s"
+
+	if got != want {
+		t.Fatalf("formatStats() = %q, want %q", got, want)
+	}
+
+	result.Usage = nil
+	if got := formatStats(result); got != "tokens: n/a | first token: 1s | total: 3s" {
+		t.Fatalf("unexpected stats without usage: %q", got)
+	}
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+// timeouts returns the timeout for the whole request and the maximum time to wait for
+// the first and each following chunk of the response. Flags take precedence over the
+// profile settings. A timeout of zero disables it, negative ones are rejected by
+// parseArgs and newProvider.
+func (app *App) timeouts() (time.Duration, time.Duration) {
+	return app.profileTimeouts(app.Profile)
+}
+
+// profileTimeouts returns the timeouts of a request with the given profile, see timeouts.
+func (app *App) profileTimeouts(profile *Profile) (time.Duration, time.Duration) {
+	timeout := DEFAULT_TIMEOUT
+	firstToken := time.Duration(0)
+
+	if profile != nil {
+		if profile.Timeout != nil {
+			timeout = profile.Timeout.Duration()
+		}
+		if profile.FirstTokenTimeout != nil {
+			firstToken = profile.FirstTokenTimeout.Duration()
+		}
+	}
+
+	if app.Flags.Timeout != nil {
+		timeout = *app.Flags.Timeout
+	}
+
+	if app.Flags.FirstTokenTimeout != nil {
+		firstToken = *app.Flags.FirstTokenTimeout
+	}
+
+	return timeout, firstToken
+}
+
+// withRequestTimeout returns a context that is cancelled with errRequestTimeout after
+// timeout. A zero timeout doesn't limit the request.
+func withRequestTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeoutCause(ctx, timeout, errRequestTimeout(timeout))
+}
+
+func newTimeoutError(message string, hint string) *provider.Error {
+	return &provider.Error{
+		Class:   provider.ErrorClassTimeout,
+		Message: message,
+		Hint:    hint,
+	}
+}
+
+func errRequestTimeout(d time.Duration) *provider.Error {
+	return newTimeoutError(
+		fmt.Sprintf("the response did not complete within %s", d),
+		"increase `timeout` in the profile or use --timeout",
+	)
+}
+
+func errFirstTokenTimeout(d time.Duration) *provider.Error {
+	return newTimeoutError(
+		fmt.Sprintf("no response from the provider within %s", d),
+		"the model may still be loading; increase `first_token_timeout` in the profile or use --first-token-timeout",
+	)
+}
+
+func errIdleTimeout(d time.Duration) *provider.Error {
+	return newTimeoutError(
+		fmt.Sprintf("the provider stopped responding for %s", d),
+		"increase `first_token_timeout` in the profile or use --first-token-timeout",
+	)
+}
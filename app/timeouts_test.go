@@ -0,0 +1,61 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mcnull/qai/shared/configfile"
+)
+
+const testTimeoutsConfig = `{
+  "profile": "unlimited",
+  "profiles": {
+    "unlimited": { "provider": "mock", "timeout": "0s", "first_token_timeout": "0s", "settings": { "response": "slow", "delay": "20ms" } },
+    "negative": { "provider": "mock", "timeout": "-1s", "settings": { "response": "slow", "delay": "20ms" } },
+    "short": { "provider": "mock", "timeout": "10ms", "settings": { "response": "slow", "delay": "200ms" } }
+  }
+}`
+
+func TestDisabledTimeouts(t *testing.T) {
+	home := newHome(t, testTimeoutsConfig)
+
+	run := runApp(t, home, "", nil, "-output", "raw", "-profile", "unlimited", "hi")
+	if run.code != EXIT_OK || run.stdout != "slow\n" {
+		t.Fatalf("expected the timeout to be disabled, got %d %q %q", run.code, run.stdout, run.stderr)
+	}
+
+	// A negative timeout is a mistake, not a way to disable it
+	run = runApp(t, home, "", nil, "-output", "raw", "-profile", "negative", "hi")
+	if run.code != EXIT_ERROR || !strings.Contains(run.stderr, "can't be negative") {
+		t.Fatalf("expected the negative timeout to be rejected, got %d %q %q", run.code, run.stdout, run.stderr)
+	}
+
+	run = runApp(t, home, "", nil, "-output", "raw", "-profile", "short", "hi")
+	if run.code != EXIT_TIMEOUT {
+		t.Fatalf("expected a timeout, got %d %q", run.code, run.stderr)
+	}
+
+	// Zero disables the timeout of the profile, as it does in a profile
+	run = runApp(t, home, "", nil, "-output", "raw", "-profile", "short", "-timeout", "0", "hi")
+	if run.code != EXIT_OK || run.stdout != "slow\n" {
+		t.Fatalf("expected the timeout to be disabled, got %d %q %q", run.code, run.stdout, run.stderr)
+	}
+
+	for _, flag := range []string{"-timeout", "-first-token-timeout"} {
+		run = runApp(t, home, "", nil, flag, "-1s", "hi")
+		if run.code != EXIT_USAGE || !strings.Contains(run.stderr, "can't be negative") {
+			t.Fatalf("%s: expected a usage error, got %d %q", flag, run.code, run.stderr)
+		}
+	}
+}
+
+func TestValidateNegativeTimeout(t *testing.T) {
+	issues, err := ValidateConfig([]byte(testTimeoutsConfig), configfile.FORMAT_JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(issues) != 1 || strings.Join(issues[0].Path, ".") != "profiles.negative.timeout" {
+		t.Fatalf("expected only the negative timeout to be reported, got %v", issues)
+	}
+}
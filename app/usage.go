@@ -0,0 +1,156 @@
+package app
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mcnull/qai/shared/ledger"
+)
+
+const USAGE_FILENAME = "usage.ndjson"
+
+// ledgerPath returns the path of the usage ledger, which lives next to the config file.
+func (app *App) ledgerPath() string {
+	return filepath.Join(filepath.Dir(app.Flags.ConfigFile), USAGE_FILENAME)
+}
+
+func (app *App) usageConfig() UsageConfig {
+	if app.Config == nil || app.Config.Usage == nil {
+		return UsageConfig{}
+	}
+	return *app.Config.Usage
+}
+
+// recordUsage appends the result to the usage ledger. Failing to do so never fails the request.
+func (app *App) recordUsage(result *Result) {
+	cfg := app.usageConfig()
+
+	if cfg.Disabled {
+		return
+	}
+
+	entry := ledger.Entry{
+		Time:         result.Timings.Start,
+		Profile:      result.Profile,
+		Provider:     result.Provider,
+		Model:        result.Model,
+		FirstTokenMs: result.Timings.TimeToFirstTokenMs,
+		LatencyMs:    result.Timings.TotalMs,
+	}
+
+	if result.Usage != nil {
+		entry.PromptTokens = result.Usage.PromptTokens
+		entry.CompletionTokens = result.Usage.CompletionTokens
+	}
+
+	if result.Error != nil {
+		entry.Error = result.Error.Class
+		if entry.Error == "" {
+			entry.Error = "error"
+		}
+	}
+
+	if cfg.StorePrompts {
+		entry.Prompt = app.Flags.Prompt
+	}
+
+	if err := ledger.Append(app.ledgerPath(), entry); err != nil {
+		app.Logger.Warn("unable to write usage ledger", "path", app.ledgerPath(), "error", err.Error())
+	}
+}
+
+// runUsage implements "qai usage".
+func (app *App) runUsage(args []string) error {
+	fs := flag.NewFlagSet(APP_NAME+" usage", flag.ContinueOnError)
+	fs.SetOutput(app.Stderr)
+
+	by := fs.String("by", ledger.BY_DAY, "Group by day, profile or model")
+	days := fs.Int("days", 0, "Only include the last number of days (0 includes everything)")
+
+	if err := fs.Parse(args); err != nil {
+		return &usageError{err: err}
+	}
+
+	entries, err := ledger.Read(app.ledgerPath())
+	if err != nil {
+		return fmt.Errorf("error reading usage ledger: %w", err)
+	}
+
+	if *days > 0 {
+		since := app.Now().AddDate(0, 0, -*days)
+		kept := entries[:0]
+
+		for _, e := range entries {
+			if e.Time.After(since) {
+				kept = append(kept, e)
+			}
+		}
+
+		entries = kept
+	}
+
+	summaries, err := ledger.Summarize(entries, *by, app.usageConfig().Prices)
+	if err != nil {
+		return err
+	}
+
+	if app.outputMode() == OUTPUT_JSON {
+		encoder := json.NewEncoder(app.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summaries)
+	}
+
+	return writeUsageTable(app.Stdout, *by, summaries)
+}
+
+func writeUsageTable(w io.Writer, by string, summaries []ledger.Summary) error {
+	if len(summaries) == 0 {
+		_, err := fmt.Fprintln(w, "No usage recorded")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintf(tw, "%s\trequests\terrors\ttokens in\ttokens out\tavg latency\tcost\t\n", by)
+
+	var total ledger.Summary
+	total.Key = "total"
+	total.Priced = true
+
+	for _, s := range summaries {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\t%s\t\n",
+			s.Key, s.Requests, s.Errors, s.PromptTokens, s.CompletionTokens,
+			formatDuration(time.Duration(s.AvgLatencyMs)*time.Millisecond), formatCost(s))
+
+		total.Requests += s.Requests
+		total.Errors += s.Errors
+		total.PromptTokens += s.PromptTokens
+		total.CompletionTokens += s.CompletionTokens
+		total.Cost += s.Cost
+		total.Priced = total.Priced && s.Priced
+	}
+
+	fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t\t%s\t\n",
+		total.Key, total.Requests, total.Errors, total.PromptTokens, total.CompletionTokens, formatCost(total))
+
+	return tw.Flush()
+}
+
+// formatCost marks costs that don't include every request with a "+".
+func formatCost(s ledger.Summary) string {
+	if s.Cost == 0 && !s.Priced {
+		return "-"
+	}
+
+	cost := fmt.Sprintf("%.4f", s.Cost)
+	if !s.Priced {
+		cost += "+"
+	}
+
+	return cost
+}
@@ -0,0 +1,382 @@
+package app
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/mcnull/qai/shared/configfile"
+	"github.com/mcnull/qai/shared/jsonc"
+	"github.com/mcnull/qai/shared/jsonmap"
+	"github.com/mcnull/qai/shared/schema"
+)
+
+// ConfigSchema returns the JSON Schema of the config file, generated from the config structs.
+// The settings of a profile are checked against the config of the provider it uses.
+func ConfigSchema() map[string]any {
+	s := schema.Generate(NewConfig())
+	s["$schema"] = schema.DRAFT
+	s["title"] = "qai config"
+
+	properties := s["properties"].(map[string]any)
+	properties["version"] = map[string]any{
+		"type":    "integer",
+		"minimum": 0,
+		"maximum": CONFIG_VERSION,
+	}
+
+	profiles := properties["profiles"].(map[string]any)
+	profile := profiles["additionalProperties"].(map[string]any)
+
+	// The provider may come from the profile it extends, see ValidateConfig
+	profile["properties"].(map[string]any)["provider"] = map[string]any{
+		"type": "string",
+		"enum": providerNames(),
+	}
+
+	var settings []any
+
+	for _, name := range providerNames() {
+		settings = append(settings, map[string]any{
+			"if": map[string]any{
+				"properties": map[string]any{"provider": map[string]any{"const": name}},
+				"required":   []string{"provider"},
+			},
+			"then": map[string]any{
+				"properties": map[string]any{"settings": schema.Generate(providerFactories[name].config())},
+			},
+		})
+	}
+
+	profile["allOf"] = settings
+
+	return s
+}
+
+// ValidateConfig checks the config document in data, written in the given format, and
+// returns every problem found. Besides the schema, it checks the default profile, URLs and
+// the system prompt template.
+func ValidateConfig(data []byte, format configfile.Format) ([]schema.Issue, error) {
+	return validateConfig(data, format, nil)
+}
+
+// validateConfig is ValidateConfig for a layer of the config. The default profile and the
+// profiles that are extended or fallen back to may be defined by the layers below, in base.
+func validateConfig(data []byte, format configfile.Format, base []map[string]any) ([]schema.Issue, error) {
+	f, err := configfile.Parse(data, format)
+	if err != nil {
+		if sErr, ok := err.(*jsonc.SyntaxError); ok {
+			return []schema.Issue{{Position: sErr.Position, Message: sErr.Message}}, nil
+		}
+		return nil, err
+	}
+
+	l, err := jsonc.NewLocator(f.JSON())
+	if err != nil {
+		return nil, err
+	}
+
+	// Positions in the JSON converted from other formats mean nothing to the user
+	position := l.Value
+	if format != configfile.FORMAT_JSON {
+		position = f.Position
+	}
+
+	raw, err := json.Marshal(ConfigSchema())
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := schema.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	issues, err := s.Check(l)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, issue := range issues {
+		if format != configfile.FORMAT_JSON {
+			issues[i].Position = f.Position(issue.Path)
+		}
+
+		// Name the supported providers instead of just rejecting the key
+		if len(issue.Path) == 2 && issue.Path[0] == "providers" && issue.Message == "unknown property" {
+			issues[i].Message = unknownProviderMessage(issue.Path[1])
+		}
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(l.JSON(), &doc); err != nil {
+		// Not an object, the schema has already reported it
+		return issues, nil
+	}
+
+	add := func(path []string, format string, a ...any) {
+		issues = append(issues, schema.Issue{
+			Path:     path,
+			Position: position(path),
+			Message:  fmt.Sprintf(format, a...),
+		})
+	}
+
+	merged := jsonmap.NewJsonMap()
+	for _, d := range append(base, doc) {
+		jsonmap.DeepAssign(merged, d)
+	}
+
+	profiles, _ := doc["profiles"].(map[string]any)
+	allProfiles, _ := merged["profiles"].(map[string]any)
+
+	if name, ok := doc["profile"].(string); ok && name != "" {
+		if _, ok := allProfiles[name]; !ok {
+			add([]string{"profile"}, "profile %q is not defined in profiles", name)
+		}
+	}
+
+	// Profiles can only be checked once they are merged with the profiles they extend
+	config := NewConfig()
+	if err := roundTrip(merged, config); err == nil {
+		for name := range profiles {
+			profile, err := config.GetProfile(name)
+
+			switch {
+			case err != nil:
+				add([]string{"profiles", name, "extends"}, "%v", err)
+			case profile.Provider == "" && len(profile.Fallback) == 0:
+				add([]string{"profiles", name}, "missing provider, set provider, extends or fallback")
+			default:
+				if _, err := config.FallbackChain(name); err != nil {
+					add([]string{"profiles", name, "fallback"}, "%v", err)
+				}
+			}
+		}
+	}
+
+	if system, ok := doc["system"].(string); ok {
+		if _, err := template.New("system").Parse(system); err != nil {
+			add([]string{"system"}, "invalid template: %v", err)
+		}
+	}
+
+	walkStrings(doc, nil, func(path []string, value string) {
+		key := path[len(path)-1]
+
+		if key != "url" && !strings.HasSuffix(key, "_url") || value == "" {
+			return
+		}
+
+		if err := checkURL(value); err != nil {
+			add(path, "invalid URL %q: %v", value, err)
+		}
+	})
+
+	schema.SortIssues(issues)
+
+	return issues, nil
+}
+
+// checkURL reports whether value is an absolute http or https URL.
+func checkURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("expected an http or https URL")
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("missing host")
+	}
+
+	return nil
+}
+
+// walkStrings calls fn with the path of every string value in v.
+func walkStrings(v any, path []string, fn func(path []string, value string)) {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, child := range value {
+			walkStrings(child, append(path[:len(path):len(path)], k), fn)
+		}
+	case []any:
+		for i, child := range value {
+			walkStrings(child, append(path[:len(path):len(path)], fmt.Sprint(i)), fn)
+		}
+	case string:
+		if len(path) > 0 {
+			fn(path, value)
+		}
+	}
+}
+
+// layerIssue is a problem found by "qai config validate", with the file or the
+// environment variable it is in.
+type layerIssue struct {
+	layer  string
+	source string
+	schema.Issue
+}
+
+func (app *App) runConfigValidate(args []string) error {
+	fs := flag.NewFlagSet(APP_NAME+" config validate", flag.ContinueOnError)
+	fs.SetOutput(app.Stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return &usageError{err: err}
+	}
+
+	var issues []layerIssue
+	var checked, invalid []string
+	var base []map[string]any
+
+	for _, layer := range app.configLayers() {
+		if !layer.exists() {
+			continue
+		}
+
+		found, err := validateLayer(layer, base)
+		if err != nil {
+			return fmt.Errorf("error validating the %s config: %w", layer.Name, err)
+		}
+
+		source := layer.Source
+		if layer.Name == LAYER_ENV {
+			source = ENV_PREFIX + "* environment variables"
+		}
+
+		checked = append(checked, source)
+		if len(found) > 0 {
+			invalid = append(invalid, source)
+		}
+
+		issues = append(issues, found...)
+		base = append(base, layer.doc)
+	}
+
+	if len(checked) == 0 {
+		return fmt.Errorf("error reading config: %s does not exist", app.Flags.ConfigFile)
+	}
+
+	if app.outputMode() == OUTPUT_JSON {
+		type jsonIssue struct {
+			Layer   string `json:"layer"`
+			Source  string `json:"source"`
+			Line    int    `json:"line"`
+			Column  int    `json:"column"`
+			Path    string `json:"path"`
+			Message string `json:"message"`
+		}
+
+		report := make([]jsonIssue, 0, len(issues))
+		for _, issue := range issues {
+			report = append(report, jsonIssue{
+				Layer:   issue.layer,
+				Source:  issue.source,
+				Line:    issue.Position.Line,
+				Column:  issue.Position.Column,
+				Path:    strings.Join(issue.Path, "."),
+				Message: issue.Message,
+			})
+		}
+
+		if err := app.writeJSON(report); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range issues {
+			printIssues(app.Stdout, issue.source, []schema.Issue{issue.Issue})
+		}
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("found %d problem(s) in %s", len(issues), strings.Join(invalid, ", "))
+	}
+
+	for _, source := range checked {
+		app.UI.Printf("%s is valid\n", source)
+	}
+
+	return nil
+}
+
+// validateLayer checks a layer of the config on top of the docs of the layers below it.
+// The values of the environment are checked as one document, the issues name the
+// variable that sets the value.
+func validateLayer(layer configLayer, base []map[string]any) ([]layerIssue, error) {
+	var data []byte
+	var format configfile.Format
+
+	switch {
+	case layer.Name == LAYER_ENV && layer.Error != "":
+		return []layerIssue{{layer: layer.Name, source: ENV_PREFIX + "*", Issue: schema.Issue{Message: layer.Error}}}, nil
+	case layer.Name == LAYER_ENV:
+		var err error
+		if data, err = json.Marshal(layer.doc); err != nil {
+			return nil, err
+		}
+		format = configfile.FORMAT_JSON
+	default:
+		var err error
+		if data, err = os.ReadFile(layer.Source); err != nil {
+			return []layerIssue{{layer: layer.Name, source: layer.Source, Issue: schema.Issue{Message: err.Error()}}}, nil
+		}
+		format = configfile.FormatOf(layer.Source)
+	}
+
+	issues, err := validateConfig(data, format, base)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]layerIssue, 0, len(issues))
+
+	for _, issue := range issues {
+		source := layer.Source
+
+		if layer.Name == LAYER_ENV {
+			// Positions in the JSON of the environment mean nothing to the user
+			issue.Position = jsonc.Position{}
+			source = envVariable(layer, issue.Path)
+		}
+
+		found = append(found, layerIssue{layer: layer.Name, source: source, Issue: issue})
+	}
+
+	return found, nil
+}
+
+// envVariable returns the variable of the env layer that sets the value at path, or
+// the value the path is part of.
+func envVariable(layer configLayer, path []string) string {
+	for _, name := range strings.Split(layer.Source, ", ") {
+		varPath := strings.Split(strings.ToLower(strings.TrimPrefix(name, ENV_PREFIX)), ENV_SEPARATOR)
+
+		n := min(len(varPath), len(path))
+		if slices.Equal(varPath[:n], path[:n]) {
+			return name
+		}
+	}
+
+	return ENV_PREFIX + "*"
+}
+
+func (app *App) runConfigSchema(args []string) error {
+	fs := flag.NewFlagSet(APP_NAME+" config schema", flag.ContinueOnError)
+	fs.SetOutput(app.Stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return &usageError{err: err}
+	}
+
+	return app.writeJSON(ConfigSchema())
+}
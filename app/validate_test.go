@@ -0,0 +1,136 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/mcnull/qai/shared/configfile"
+)
+
+func TestValidateConfig(t *testing.T) {
+	data := []byte(`{
+  "profile": "wrok",
+  "system": "Hi {{.Platform",
+  "providers": {
+    "ollama": { "url": "localhost:11434" },
+    "olama": {}
+  },
+  "profiles": {
+    "default": { "provider": "ollama", "settings": { "modle": "llama3" } },
+    "gh": { "provider": "gihub" },
+    "m": { "provider": "mock", "timeout": "soon", "settings": { "chunk_size": "4" } }
+  }
+}`)
+
+	issues, err := ValidateConfig(data, configfile.FORMAT_JSON)
+	if err != nil {
+		t.Fatalf("ValidateConfig failed: %v", err)
+	}
+
+	want := []string{
+		"2:14: profile",
+		"3:13: system",
+		"5:24: providers.ollama.url",
+		"6:5: providers.olama",
+		"9:54: profiles.default.settings.modle",
+		"10:25: profiles.gh.provider",
+		"11:43: profiles.m.timeout",
+		"11:79: profiles.m.settings.chunk_size",
+	}
+
+	if len(issues) != len(want) {
+		t.Fatalf("expected %d issues, got %d: %v", len(want), len(issues), issues)
+	}
+
+	for i, issue := range issues {
+		if got := issue.String(); len(got) < len(want[i]) || got[:len(want[i])] != want[i] {
+			t.Fatalf("issue %d: expected %q, got %q", i, want[i], got)
+		}
+	}
+}
+
+func TestValidateDefaultConfig(t *testing.T) {
+	data, err := json.Marshal(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := ValidateConfig(data, configfile.FORMAT_JSON)
+	if err != nil {
+		t.Fatalf("ValidateConfig failed: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("expected the default config to be valid, got %v", issues)
+	}
+}
+
+func TestValidateConfigSyntaxError(t *testing.T) {
+	issues, err := ValidateConfig([]byte("{\n  \"profile\": \"default\"\n  \"profiles\": {}\n}"), configfile.FORMAT_JSON)
+	if err != nil {
+		t.Fatalf("ValidateConfig failed: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Position.Line != 3 || issues[0].Position.Column != 3 {
+		t.Fatalf("expected a syntax error at 3:3, got %v", issues)
+	}
+}
+
+func TestValidateConfigYAML(t *testing.T) {
+	data := []byte(`# Local models only
+profile: default
+profiles:
+  default:
+    provider: ollama
+    settings:
+      modle: llama3
+`)
+
+	issues, err := ValidateConfig(data, configfile.FORMAT_YAML)
+	if err != nil {
+		t.Fatalf("ValidateConfig failed: %v", err)
+	}
+
+	if len(issues) != 1 || issues[0].Position.String() != "7:7" {
+		t.Fatalf("expected an unknown property at 7:7, got %v", issues)
+	}
+}
+
+func TestValidateConfigComments(t *testing.T) {
+	data := []byte(`{
+  // The profile used without --profile
+  "profile": "default",
+  "profiles": {
+    "default": { "provider": "ollama", }, /* local */
+  },
+}`)
+
+	issues, err := ValidateConfig(data, configfile.FORMAT_JSON)
+	if err != nil {
+		t.Fatalf("ValidateConfig failed: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("expected a config with comments to be valid, got %v", issues)
+	}
+}
+
+// The published schema is regenerated with: go run . config schema > config.schema.json
+func TestConfigSchemaFile(t *testing.T) {
+	published, err := os.ReadFile("../config.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(ConfigSchema()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(published, buf.Bytes()) {
+		t.Fatal("config.schema.json is out of date, regenerate it with: go run . config schema > config.schema.json")
+	}
+}
@@ -17,12 +17,30 @@ func NewChatMessage(role, content string) *ChatMessage {
 }
 
 type ChatRequest struct {
-	Model       string         `json:"model"`
-	Temperature float32        `json:"temperature"`
-	Top_p       float32        `json:"top_p"`
-	N           int            `json:"n"`
-	Stream      bool           `json:"stream"`
-	Messages    []*ChatMessage `json:"messages"`
+	Model          string          `json:"model"`
+	Temperature    float32         `json:"temperature"`
+	Top_p          float32         `json:"top_p"`
+	N              int             `json:"n"`
+	Stream         bool            `json:"stream"`
+	StreamOptions  *StreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	Messages       []*ChatMessage  `json:"messages"`
+}
+
+// ResponseFormat asks the model to answer with JSON matching a schema.
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JsonSchema *JsonSchema `json:"json_schema,omitempty"`
+}
+
+type JsonSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict"`
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 func NewChatRequest(model string, messages []*ChatMessage) *ChatRequest {
@@ -39,3 +57,21 @@ func NewChatRequest(model string, messages []*ChatMessage) *ChatRequest {
 func (c *ChatRequest) ToJson() ([]byte, error) {
 	return json.Marshal(c)
 }
+
+// ChatStreamChunk is a single server-sent event of a streamed chat completion.
+type ChatStreamChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index        int          `json:"index"`
+		Delta        *ChatMessage `json:"delta,omitempty"`
+		FinishReason string       `json:"finish_reason,omitempty"`
+	} `json:"choices"`
+	Usage *ChatUsage `json:"usage,omitempty"`
+}
+
+type ChatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
@@ -6,8 +6,11 @@ import (
 )
 
 type Config struct {
-	Model string `json:"model"`
-	Token string `json:"token"`
+	Model   string `json:"model"`
+	Token   string `json:"token"`
+	Host    string `json:"host,omitempty"`
+	ApiHost string `json:"api_host,omitempty"`
+	ChatURL string `json:"chat_url,omitempty"`
 }
 
 func NewConfig() provider.IConfig {
@@ -36,5 +39,22 @@ func (c *Config) Merge(other provider.IConfig) error {
 		c.Token = otherConfig.Token
 	}
 
+	if otherConfig.Host != "" {
+		c.Host = otherConfig.Host
+	}
+
+	if otherConfig.ApiHost != "" {
+		c.ApiHost = otherConfig.ApiHost
+	}
+
+	if otherConfig.ChatURL != "" {
+		c.ChatURL = otherConfig.ChatURL
+	}
+
 	return nil
 }
+
+// Endpoints returns the endpoints for the configured GitHub host.
+func (c *Config) Endpoints() *Endpoints {
+	return NewEndpoints(c.Host, c.ApiHost, c.ChatURL)
+}
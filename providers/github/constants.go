@@ -2,4 +2,5 @@ package github
 
 const (
 	DEFAULT_MODEL = "gpt-3.5-turbo"
+	DEFAULT_HOST  = "github.com"
 )
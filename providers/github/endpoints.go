@@ -0,0 +1,78 @@
+package github
+
+import (
+	"strings"
+)
+
+const DEVICE_CODE_URL = "https://github.com/login/device/code"
+const OAUTH_TOKEN_URL = "https://github.com/login/oauth/access_token"
+const API_TOKEN_URL = "https://api.github.com/copilot_internal/v2/token"
+
+// Endpoints contains the URLs used during the device flow login, the Copilot token exchange and chat.
+type Endpoints struct {
+	DeviceCodeURL string
+	OAuthTokenURL string
+	ApiTokenURL   string
+	ChatURL       string
+}
+
+// DefaultEndpoints returns the endpoints for github.com.
+func DefaultEndpoints() *Endpoints {
+	return &Endpoints{
+		DeviceCodeURL: DEVICE_CODE_URL,
+		OAuthTokenURL: OAUTH_TOKEN_URL,
+		ApiTokenURL:   API_TOKEN_URL,
+		ChatURL:       GITHUB_CHAT_URL,
+	}
+}
+
+// NewEndpoints returns the endpoints for the given GitHub host and API host.
+// Hosts may be given with or without scheme; https is assumed when omitted.
+// An empty apiHost is derived from host ("api.<host>").
+// An empty chatURL falls back to the public Copilot chat endpoint.
+func NewEndpoints(host, apiHost, chatURL string) *Endpoints {
+	if host == "" {
+		host = DEFAULT_HOST
+	}
+
+	if apiHost == "" {
+		apiHost = "api." + stripScheme(host)
+	}
+
+	if chatURL == "" {
+		chatURL = GITHUB_CHAT_URL
+	}
+
+	hostURL := baseURL(host)
+	apiURL := baseURL(apiHost)
+
+	return &Endpoints{
+		DeviceCodeURL: hostURL + "/login/device/code",
+		OAuthTokenURL: hostURL + "/login/oauth/access_token",
+		ApiTokenURL:   apiURL + "/copilot_internal/v2/token",
+		ChatURL:       chatURL,
+	}
+}
+
+// chatURLFromApi returns the chat completions URL for the Copilot API base URL
+// that is returned with the API token.
+func chatURLFromApi(api string) string {
+	return strings.TrimSuffix(api, "/") + "/chat/completions"
+}
+
+func baseURL(host string) string {
+	host = strings.TrimSuffix(host, "/")
+
+	if strings.Contains(host, "://") {
+		return host
+	}
+
+	return "https://" + host
+}
+
+func stripScheme(host string) string {
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	return strings.TrimSuffix(host, "/")
+}
@@ -0,0 +1,60 @@
+package github
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewEndpointsDefaultHost(t *testing.T) {
+	got := NewEndpoints("", "", "")
+
+	if !reflect.DeepEqual(got, DefaultEndpoints()) {
+		t.Fatalf("expected default endpoints, got %+v", got)
+	}
+}
+
+func TestNewEndpointsEnterpriseHost(t *testing.T) {
+	got := NewEndpoints("octocorp.ghe.com", "", "")
+
+	want := &Endpoints{
+		DeviceCodeURL: "https://octocorp.ghe.com/login/device/code",
+		OAuthTokenURL: "https://octocorp.ghe.com/login/oauth/access_token",
+		ApiTokenURL:   "https://api.octocorp.ghe.com/copilot_internal/v2/token",
+		ChatURL:       GITHUB_CHAT_URL,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected endpoints: %+v", got)
+	}
+}
+
+func TestNewEndpointsExplicitApiHost(t *testing.T) {
+	got := NewEndpoints("http://localhost:8080/", "http://localhost:8081", "http://localhost:8082/chat")
+
+	want := &Endpoints{
+		DeviceCodeURL: "http://localhost:8080/login/device/code",
+		OAuthTokenURL: "http://localhost:8080/login/oauth/access_token",
+		ApiTokenURL:   "http://localhost:8081/copilot_internal/v2/token",
+		ChatURL:       "http://localhost:8082/chat",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected endpoints: %+v", got)
+	}
+}
+
+func TestChatURLPrefersConfiguredURL(t *testing.T) {
+	token := &ApiTokenResponse{}
+	token.Endpoints.Api = "https://api.business.githubcopilot.com/"
+
+	p := &GitHubProvider{config: Config{}, endpoints: NewEndpoints("", "", "")}
+	if got, want := p.chatURL(token), "https://api.business.githubcopilot.com/chat/completions"; got != want {
+		t.Fatalf("chatURL() = %q, want %q", got, want)
+	}
+
+	cfg := Config{ChatURL: "https://copilot.example.com/chat"}
+	p = &GitHubProvider{config: cfg, endpoints: cfg.Endpoints()}
+	if got, want := p.chatURL(token), "https://copilot.example.com/chat"; got != want {
+		t.Fatalf("chatURL() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,46 @@
+package github
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+const PROVIDER_NAME = "github"
+
+// maxErrorBody limits how much of an error response is read.
+const maxErrorBody = 64 * 1024
+
+// newStatusError creates a provider error from a non-successful Copilot response.
+func newStatusError(resp *http.Response) *provider.Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	e := provider.NewStatusError(PROVIDER_NAME, resp.StatusCode, body)
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		e.Hint = "token expired or revoked, run `qai --github-login`"
+	case resp.StatusCode == http.StatusForbidden:
+		e.Hint = "make sure GitHub Copilot is enabled for your account"
+	case resp.StatusCode == http.StatusNotFound:
+		e.Hint = "make sure GitHub Copilot is enabled for your account and the host settings are correct"
+	case e.Class == provider.ErrorClassRateLimit:
+		e.Hint = "Copilot rate limit reached, wait a moment and try again"
+	case e.Class == provider.ErrorClassRequest:
+		e.Hint = "check the `model` setting of the profile"
+	}
+
+	return e
+}
+
+// newRequestError creates a provider error for a request that did not get a response.
+func newRequestError(err error) *provider.Error {
+	e := provider.NewRequestError(PROVIDER_NAME, err)
+
+	if e.Class == provider.ErrorClassConnection {
+		e.Hint = "check your network connection and the host settings"
+	}
+
+	return e
+}
@@ -13,7 +13,8 @@ import (
 
 type GitHubProvider struct {
 	provider.ProviderBase
-	config Config
+	config    Config
+	endpoints *Endpoints
 }
 
 func NewGitHubProvider(config provider.IConfig, appCtx *provider.AppContext) (provider.IProvider, error) {
@@ -28,17 +29,23 @@ func NewGitHubProvider(config provider.IConfig, appCtx *provider.AppContext) (pr
 	}
 
 	p := &GitHubProvider{
-		config: *cfg,
+		config:    *cfg,
+		endpoints: cfg.Endpoints(),
 	}
 
-	p.ProviderBase = *provider.NewProviderBase("github", appCtx)
+	p.ProviderBase = *provider.NewProviderBase(PROVIDER_NAME, appCtx)
 
 	return p, nil
 }
 
 func (p *GitHubProvider) Init() error {
 	if p.config.Token == "" {
-		return fmt.Errorf("missing github token.\n\nUse --github-login to create a new token.")
+		return &provider.Error{
+			Provider: PROVIDER_NAME,
+			Class:    provider.ErrorClassAuth,
+			Message:  "missing github token",
+			Hint:     "run `qai --github-login` to create a new token",
+		}
 	}
 
 	return nil
@@ -53,7 +60,7 @@ func (p *GitHubProvider) Generate(ctx context.Context, request provider.Generate
 		defer close(responseChan)
 		defer close(errorChan)
 
-		apiToken, err := requestApiToken(p.config.Token)
+		apiToken, err := requestApiToken(ctx, p.HTTPClient(), p.endpoints, p.config.Token)
 
 		if err != nil {
 			errorChan <- fmt.Errorf("failed to request API token: %w", err)
@@ -67,7 +74,21 @@ func (p *GitHubProvider) Generate(ctx context.Context, request provider.Generate
 
 		chatReq := NewChatRequest(p.config.Model, chatMessages)
 
-		chatReq.Stream = !p.Flags().Color
+		chatReq.Stream = request.Stream
+
+		if chatReq.Stream {
+			chatReq.StreamOptions = &StreamOptions{IncludeUsage: true}
+		}
+
+		if request.Schema != nil {
+			chatReq.ResponseFormat = &ResponseFormat{
+				Type: "json_schema",
+				JsonSchema: &JsonSchema{
+					Name:   "response",
+					Schema: request.Schema,
+				},
+			}
+		}
 
 		jsonBody, err := chatReq.ToJson()
 
@@ -103,8 +124,8 @@ func (p *GitHubProvider) Generate(ctx context.Context, request provider.Generate
 			}
 		*/
 
-		client := &http.Client{}
-		req, err := http.NewRequestWithContext(ctx, "POST", GITHUB_CHAT_URL, bytes.NewBuffer(jsonBody))
+		client := p.HTTPClient()
+		req, err := http.NewRequestWithContext(ctx, "POST", p.chatURL(apiToken), bytes.NewBuffer(jsonBody))
 
 		if err != nil {
 			errorChan <- fmt.Errorf("error creating request: %w", err)
@@ -112,22 +133,28 @@ func (p *GitHubProvider) Generate(ctx context.Context, request provider.Generate
 		}
 
 		req.Header.Set("User-Agent", "github.com/mcnull/qai")
-		req.Header.Set("Authorization", "Bearer "+apiToken)
+		req.Header.Set("Authorization", "Bearer "+apiToken.Token)
 		req.Header.Set("Editor-Version", "github.com/mcnull/qai/0.1.0")
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("Copilot-Integration-Id", "vscode-chat")
 		req.Header.Set("Accept", "application/json")
 
-		client.Do(req)
 		resp, err := client.Do(req)
 		if err != nil {
-			errorChan <- fmt.Errorf("error sending request: %w", err)
+			errorChan <- newRequestError(err)
 			return
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			errorChan <- fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+			errorChan <- newStatusError(resp)
+			return
+		}
+
+		if chatReq.Stream {
+			if err := readStream(ctx, resp.Body, responseChan); err != nil {
+				errorChan <- err
+			}
 			return
 		}
 
@@ -173,6 +200,14 @@ func (p *GitHubProvider) Generate(ctx context.Context, request provider.Generate
 				Raw:      response,
 				Response: content,
 				Done:     true,
+				Model:    jsonmap.GetOrDefault(response, "model", ""),
+			}
+
+			if u, ok := jsonmap.Get[map[string]any](response, "usage"); ok {
+				var usage ChatUsage
+				if err := jsonmap.JsonMap(u).ToStruct(&usage); err == nil {
+					providerResp.Usage = usage.toProviderUsage()
+				}
 			}
 
 			select {
@@ -189,3 +224,18 @@ func (p *GitHubProvider) Generate(ctx context.Context, request provider.Generate
 
 	return responseChan, errorChan
 }
+
+// chatURL returns the chat endpoint to use. An explicitly configured URL wins over
+// the API endpoint advertised with the Copilot token.
+func (p *GitHubProvider) chatURL(apiToken *ApiTokenResponse) string {
+	if p.config.ChatURL == "" && apiToken.Endpoints.Api != "" {
+		return chatURLFromApi(apiToken.Endpoints.Api)
+	}
+
+	return p.endpoints.ChatURL
+}
+
+// EnforcesSchema reports that the schema of a request is sent as the response format.
+func (p *GitHubProvider) EnforcesSchema() bool {
+	return true
+}
@@ -2,21 +2,43 @@ package github
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"github.com/mcnull/qai/shared/throbber"
-	"github.com/mcnull/qai/shared/utils"
 	"strings"
 	"time"
+
+	"github.com/mcnull/qai/shared/desktop"
+	"github.com/mcnull/qai/shared/httpclient"
+	"github.com/mcnull/qai/shared/logging"
+	"github.com/mcnull/qai/shared/throbber"
+	"github.com/mcnull/qai/shared/ui"
 )
 
-const DEVICE_CODE_URL = "https://github.com/login/device/code"
 const COPILOT_API_KEY = "Iv1.b507a08c87ecfe98"
-const OAUTH_TOKEN_URL = "https://github.com/login/oauth/access_token"
-const API_TOKEN_URL = "https://api.github.com/copilot_internal/v2/token"
+
+// DEFAULT_POLL_INTERVAL is used when the device code response does not specify an interval.
+const DEFAULT_POLL_INTERVAL = 5 * time.Second
+
+// slowDownIncrement is added to the polling interval every time GitHub answers with "slow_down".
+var slowDownIncrement = 5 * time.Second
+
+// Injected for testing
+var (
+	openURL        = desktop.OpenURL
+	writeClipboard = desktop.WriteClipboard
+)
+
+var (
+	ErrDeviceCodeExpired = errors.New("device code has expired")
+	ErrAccessDenied      = errors.New("access denied")
+	ErrLoginTimedOut     = errors.New("timed out waiting for authorization")
+)
 
 type DeviceCodeResponse struct {
 	DeviceCode      string `json:"device_code"`
@@ -39,47 +61,82 @@ type ApiTokenResponse struct {
 	ExpiresAt int    `json:"expires_at"`
 	RefreshIn int    `json:"refresh_in"`
 	Token     string `json:"token"`
+	Endpoints struct {
+		Api string `json:"api,omitempty"`
+	} `json:"endpoints"`
 }
 
-func Login(debug bool) (string, error) {
+// Login runs the GitHub device flow and returns the resulting OAuth token.
+// The flow is aborted when ctx is cancelled or when the device code expires.
+func Login(ctx context.Context, client *httpclient.Client, endpoints *Endpoints, out *ui.UI, logger *slog.Logger) (string, error) {
+
+	if client == nil {
+		client = httpclient.Default()
+	}
+
+	if out == nil {
+		out = ui.Default()
+	}
+
+	if logger == nil {
+		logger = logging.Discard()
+	}
+
+	if endpoints == nil {
+		endpoints = DefaultEndpoints()
+	}
 
 	// 1. Request device and user codes
-	dc, err := getDeviceCode()
+	dc, err := getDeviceCode(ctx, client, endpoints)
 	if err != nil {
 		return "", fmt.Errorf("failed to request device code: %w", err)
 	}
 
-	if debug {
-		utils.Dump(dc)
-	}
+	logger.Debug("device code",
+		"user_code", dc.UserCode,
+		"verification_uri", dc.VerificationURI,
+		"expires_in", dc.ExpiresIn,
+		"interval", dc.Interval,
+	)
 
 	// 2. Prompt user to authorize
-	fmt.Printf("Please visit: %s\n", dc.VerificationURI)
-	fmt.Printf("And enter code: %s\n", dc.UserCode)
+	out.Printf("Please visit: %s\n", dc.VerificationURI)
+	out.Printf("And enter code: %s\n", dc.UserCode)
+
+	if err := writeClipboard(dc.UserCode); err == nil {
+		out.Println("The code has been copied to your clipboard.")
+	}
+
+	if err := openURL(dc.VerificationURI); err == nil {
+		out.Println("Opened the verification page in your browser.")
+	}
 
 	// 3. Poll for the token
 	interval := time.Duration(dc.Interval) * time.Second
-	accessToken, err := pollForToken(dc.DeviceCode, interval)
+	if interval <= 0 {
+		interval = DEFAULT_POLL_INTERVAL
+	}
+
+	expiresIn := time.Duration(dc.ExpiresIn) * time.Second
+
+	accessToken, err := pollForToken(ctx, client, endpoints, out, dc.DeviceCode, interval, expiresIn)
 	if err != nil {
 		return "", fmt.Errorf("failed to poll for token: %w", err)
 	}
 
-	fmt.Println("Successfully authenticated!")
+	out.Println("Successfully authenticated!")
 
 	return accessToken, nil
 }
 
-func requestOAuthToken(deviceCode string) (*OAuthTokenResponse, error) {
-
-	clientID := COPILOT_API_KEY
-	tokenURL := OAUTH_TOKEN_URL
+func requestOAuthToken(ctx context.Context, client *httpclient.Client, endpoints *Endpoints, deviceCode string) (*OAuthTokenResponse, error) {
 
 	data := url.Values{}
-	data.Set("client_id", clientID)
+	data.Set("client_id", COPILOT_API_KEY)
 	data.Set("device_code", deviceCode)
 	data.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
 
-	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoints.OAuthTokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create token request: %w", err)
 	}
@@ -87,7 +144,6 @@ func requestOAuthToken(deviceCode string) (*OAuthTokenResponse, error) {
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send token request: %w", err)
@@ -113,51 +169,67 @@ func requestOAuthToken(deviceCode string) (*OAuthTokenResponse, error) {
 	return &tokenResponse, nil
 }
 
-func pollForToken(deviceCode string, interval time.Duration) (string, error) {
-	throbber := throbber.NewThrobber().
-		WithMessage("Waiting for authorization ... ").
-		WithThrob(throbber.ThrobByName("dots"))
+func pollForToken(ctx context.Context, client *httpclient.Client, endpoints *Endpoints, out *ui.UI, deviceCode string, interval, expiresIn time.Duration) (string, error) {
 
-	throbber.Start()
+	if expiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, expiresIn, ErrLoginTimedOut)
+		defer cancel()
+	}
+
+	throbber := out.StartThrobber("Waiting for authorization ... ", throbber.ThrobByName("dots"))
 	defer throbber.Stop()
 
 	for {
-		tokenResponse, err := requestOAuthToken(deviceCode)
+		tokenResponse, err := requestOAuthToken(ctx, client, endpoints, deviceCode)
 		if err != nil {
+			// Cancelled, or the device code expired before the server said so
+			if ctx.Err() != nil {
+				return "", context.Cause(ctx)
+			}
 			// This would be an error in making the request itself or decoding, not a GitHub error code.
 			return "", fmt.Errorf("error requesting token: %w", err)
 		}
 
-		if tokenResponse.Error != "" {
-			switch tokenResponse.Error {
-			case "authorization_pending":
-				// User has not yet entered the code. Wait, then poll again.
-				time.Sleep(interval)
-				continue
-			case "slow_down":
-				// App polled too fast. Wait for the interval plus 5 seconds, then poll again.
-				time.Sleep(interval + 5*time.Second)
-				continue
-			case "expired_token":
-				return "", fmt.Errorf("device code has expired. Please try the login process again. Description: %s", tokenResponse.ErrorDescription)
-			case "access_denied":
-				return "", fmt.Errorf("login cancelled by user or access denied. Description: %s", tokenResponse.ErrorDescription)
-			default:
-				return "", fmt.Errorf("received error from token endpoint: %s. Description: %s", tokenResponse.Error, tokenResponse.ErrorDescription)
+		switch tokenResponse.Error {
+		case "":
+			if tokenResponse.AccessToken != "" {
+				return tokenResponse.AccessToken, nil
 			}
+			// No access token and no error is unexpected; wait and poll again.
+		case "authorization_pending":
+			// User has not yet entered the code. Wait, then poll again.
+		case "slow_down":
+			// App polled too fast. Increase the interval as required by the spec.
+			interval += slowDownIncrement
+		case "expired_token":
+			return "", fmt.Errorf("%w. Please try the login process again. Description: %s", ErrDeviceCodeExpired, tokenResponse.ErrorDescription)
+		case "access_denied":
+			return "", fmt.Errorf("login cancelled by user or %w. Description: %s", ErrAccessDenied, tokenResponse.ErrorDescription)
+		default:
+			return "", fmt.Errorf("received error from token endpoint: %s. Description: %s", tokenResponse.Error, tokenResponse.ErrorDescription)
 		}
 
-		if tokenResponse.AccessToken != "" {
-			return tokenResponse.AccessToken, nil
+		if err := sleep(ctx, interval); err != nil {
+			return "", context.Cause(ctx)
 		}
+	}
+}
+
+// sleep waits for the given duration or until ctx is done, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-		// If no access token and no error, something is unexpected. Wait and retry once more after a delay.
-		// Or, this could be a point to return an error if this state is not expected.
-		time.Sleep(interval)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
 }
 
-func getDeviceCode() (*DeviceCodeResponse, error) {
+func getDeviceCode(ctx context.Context, client *httpclient.Client, endpoints *Endpoints) (*DeviceCodeResponse, error) {
 
 	// POST https://github.com/login/device/code
 	// Accept: application/json
@@ -168,7 +240,7 @@ func getDeviceCode() (*DeviceCodeResponse, error) {
 	data := url.Values{}
 	data.Set("client_id", COPILOT_API_KEY)
 
-	req, err := http.NewRequest("POST", DEVICE_CODE_URL, bytes.NewBufferString(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoints.DeviceCodeURL, bytes.NewBufferString(data.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -176,7 +248,6 @@ func getDeviceCode() (*DeviceCodeResponse, error) {
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
@@ -206,36 +277,35 @@ func readAll(r io.Reader) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func requestApiToken(oauth_token string) (string, error) {
+func requestApiToken(ctx context.Context, client *httpclient.Client, endpoints *Endpoints, oauth_token string) (*ApiTokenResponse, error) {
 	// GET https://api.github.com/copilot_internal/v2/token
 	// Authorization: Bearer {{$dotenv GITHUB_AUTH_TOKEN}}
 	// User-Agent: github.com/mcnull/qai
 	// Accept: application/json
 
-	req, err := http.NewRequest("GET", API_TOKEN_URL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoints.ApiTokenURL, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+oauth_token)
 	req.Header.Set("User-Agent", "github.com/mcnull/qai")
 	req.Header.Set("Accept", "application/json")
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, newRequestError(err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newStatusError(resp)
 	}
 	var t ApiTokenResponse
 	err = json.NewDecoder(resp.Body).Decode(&t)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if t.Token == "" {
-		return "", fmt.Errorf("empty token in response")
+		return nil, fmt.Errorf("empty token in response")
 	}
 
-	return t.Token, nil
+	return &t, nil
 }
@@ -0,0 +1,234 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mcnull/qai/shared/httpclient"
+	"github.com/mcnull/qai/shared/ui"
+)
+
+var (
+	testClient = httpclient.New(0)
+	testUI     = ui.New(io.Discard)
+)
+
+// newTokenServer returns a test server whose OAuth token endpoint answers with the given
+// error codes in order, followed by an access token once the list is exhausted.
+func newTokenServer(t *testing.T, codes ...string) (*httptest.Server, *Endpoints, *int32) {
+	t.Helper()
+
+	var calls int32
+
+	origOpenURL, origWriteClipboard := openURL, writeClipboard
+	t.Cleanup(func() { openURL, writeClipboard = origOpenURL, origWriteClipboard })
+
+	openURL = func(string) error { return nil }
+	writeClipboard = func(string) error { return nil }
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/login/device/code", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"device_code":"dc","user_code":"AB-12","verification_uri":"https://example.com/device","expires_in":900,"interval":5}`)
+	})
+
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		if r.Form.Get("device_code") != "dc" {
+			t.Errorf("unexpected device code: %q", r.Form.Get("device_code"))
+		}
+
+		n := int(atomic.AddInt32(&calls, 1)) - 1
+
+		w.Header().Set("Content-Type", "application/json")
+		if n < len(codes) {
+			fmt.Fprintf(w, `{"error":%q,"error_description":"test"}`, codes[n])
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"gho_token","token_type":"bearer"}`)
+	})
+
+	mux.HandleFunc("/copilot_internal/v2/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"token":"api_token","expires_at":0,"refresh_in":1500,"endpoints":{"api":"https://api.example.ghe.com"}}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	endpoints := &Endpoints{
+		DeviceCodeURL: srv.URL + "/login/device/code",
+		OAuthTokenURL: srv.URL + "/login/oauth/access_token",
+		ApiTokenURL:   srv.URL + "/copilot_internal/v2/token",
+	}
+
+	return srv, endpoints, &calls
+}
+
+func withSlowDownIncrement(t *testing.T, d time.Duration) {
+	t.Helper()
+	orig := slowDownIncrement
+	slowDownIncrement = d
+	t.Cleanup(func() { slowDownIncrement = orig })
+}
+
+func TestGetDeviceCode(t *testing.T) {
+	_, endpoints, _ := newTokenServer(t)
+
+	dc, err := getDeviceCode(context.Background(), testClient, endpoints)
+	if err != nil {
+		t.Fatalf("getDeviceCode failed: %v", err)
+	}
+	if dc.DeviceCode != "dc" || dc.UserCode != "AB-12" || dc.ExpiresIn != 900 || dc.Interval != 5 {
+		t.Fatalf("unexpected device code response: %+v", dc)
+	}
+}
+
+func TestPollForTokenAuthorizationPending(t *testing.T) {
+	_, endpoints, calls := newTokenServer(t, "authorization_pending", "authorization_pending")
+
+	token, err := pollForToken(context.Background(), testClient, endpoints, testUI, "dc", time.Millisecond, time.Minute)
+	if err != nil {
+		t.Fatalf("pollForToken failed: %v", err)
+	}
+	if token != "gho_token" {
+		t.Fatalf("unexpected token: %q", token)
+	}
+	if n := atomic.LoadInt32(calls); n != 3 {
+		t.Fatalf("expected 3 token requests, got %d", n)
+	}
+}
+
+func TestPollForTokenSlowDown(t *testing.T) {
+	withSlowDownIncrement(t, 20*time.Millisecond)
+	_, endpoints, calls := newTokenServer(t, "slow_down", "slow_down")
+
+	start := time.Now()
+	token, err := pollForToken(context.Background(), testClient, endpoints, testUI, "dc", time.Millisecond, time.Minute)
+	if err != nil {
+		t.Fatalf("pollForToken failed: %v", err)
+	}
+	if token != "gho_token" {
+		t.Fatalf("unexpected token: %q", token)
+	}
+	if n := atomic.LoadInt32(calls); n != 3 {
+		t.Fatalf("expected 3 token requests, got %d", n)
+	}
+
+	// 1ms+20ms after the first slow_down, 1ms+40ms after the second.
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Fatalf("interval was not increased on slow_down, elapsed %s", elapsed)
+	}
+}
+
+func TestPollForTokenExpiredToken(t *testing.T) {
+	_, endpoints, _ := newTokenServer(t, "authorization_pending", "expired_token")
+
+	_, err := pollForToken(context.Background(), testClient, endpoints, testUI, "dc", time.Millisecond, time.Minute)
+	if !errors.Is(err, ErrDeviceCodeExpired) {
+		t.Fatalf("expected ErrDeviceCodeExpired, got %v", err)
+	}
+}
+
+func TestPollForTokenAccessDenied(t *testing.T) {
+	_, endpoints, _ := newTokenServer(t, "access_denied")
+
+	_, err := pollForToken(context.Background(), testClient, endpoints, testUI, "dc", time.Millisecond, time.Minute)
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("expected ErrAccessDenied, got %v", err)
+	}
+}
+
+func TestPollForTokenEnforcesExpiry(t *testing.T) {
+	codes := make([]string, 1000)
+	for i := range codes {
+		codes[i] = "authorization_pending"
+	}
+	_, endpoints, _ := newTokenServer(t, codes...)
+
+	// ErrDeviceCodeExpired is reserved for the server's expired_token
+	_, err := pollForToken(context.Background(), testClient, endpoints, testUI, "dc", 10*time.Millisecond, 50*time.Millisecond)
+	if !errors.Is(err, ErrLoginTimedOut) || errors.Is(err, ErrDeviceCodeExpired) {
+		t.Fatalf("expected ErrLoginTimedOut, got %v", err)
+	}
+}
+
+func TestPollForTokenCancelCause(t *testing.T) {
+	_, endpoints, _ := newTokenServer(t, "authorization_pending", "authorization_pending")
+
+	errShutdown := errors.New("shutting down")
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errShutdown)
+
+	_, err := pollForToken(ctx, testClient, endpoints, testUI, "dc", time.Millisecond, time.Minute)
+	if !errors.Is(err, errShutdown) {
+		t.Fatalf("expected the cause of the cancellation, got %v", err)
+	}
+}
+
+func TestPollForTokenCancelled(t *testing.T) {
+	_, endpoints, _ := newTokenServer(t, "authorization_pending", "authorization_pending")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := pollForToken(ctx, testClient, endpoints, testUI, "dc", time.Millisecond, time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	_, endpoints, _ := newTokenServer(t)
+
+	var opened, copied string
+	openURL = func(url string) error { opened = url; return nil }
+	writeClipboard = func(text string) error { copied = text; return nil }
+
+	token, err := Login(context.Background(), testClient, endpoints, testUI, nil)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if token != "gho_token" {
+		t.Fatalf("unexpected token: %q", token)
+	}
+	if opened != "https://example.com/device" {
+		t.Fatalf("verification url was not opened, got %q", opened)
+	}
+	if copied != "AB-12" {
+		t.Fatalf("user code was not copied, got %q", copied)
+	}
+}
+
+func TestRequestApiToken(t *testing.T) {
+	_, endpoints, _ := newTokenServer(t)
+
+	token, err := requestApiToken(context.Background(), testClient, endpoints, "gho_token")
+	if err != nil {
+		t.Fatalf("requestApiToken failed: %v", err)
+	}
+	if token.Token != "api_token" {
+		t.Fatalf("unexpected api token: %q", token.Token)
+	}
+	if token.Endpoints.Api != "https://api.example.ghe.com" {
+		t.Fatalf("unexpected api endpoint: %q", token.Endpoints.Api)
+	}
+
+	if _, err := requestApiToken(context.Background(), testClient, endpoints, "wrong"); err == nil {
+		t.Fatal("expected error for unauthorized token")
+	}
+}
@@ -0,0 +1,98 @@
+package github
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+// readStream reads a server-sent event stream of chat completion chunks and
+// forwards the content to responseChan. The final response carries the model and usage.
+func readStream(ctx context.Context, body io.Reader, responseChan chan<- provider.GenerateResponse) error {
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var model string
+	var usage *provider.Usage
+
+	send := func(resp provider.GenerateResponse) error {
+		select {
+		case responseChan <- resp:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			// Empty lines separate events, other fields are not used
+			continue
+		}
+
+		data = strings.TrimSpace(data)
+
+		if data == "[DONE]" {
+			return send(provider.GenerateResponse{
+				Raw:   data,
+				Done:  true,
+				Model: model,
+				Usage: usage,
+			})
+		}
+
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("error decoding stream chunk: %w", err)
+		}
+
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+
+		if chunk.Usage != nil {
+			usage = chunk.Usage.toProviderUsage()
+		}
+
+		content := ""
+		for _, choice := range chunk.Choices {
+			if choice.Index == 0 && choice.Delta != nil {
+				content += choice.Delta.Content
+			}
+		}
+
+		if content == "" {
+			continue
+		}
+
+		if err := send(provider.GenerateResponse{
+			Raw:      chunk,
+			Response: content,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading stream: %w", err)
+	}
+
+	return fmt.Errorf("stream ended unexpectedly")
+}
+
+func (u *ChatUsage) toProviderUsage() *provider.Usage {
+	return &provider.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
@@ -0,0 +1,74 @@
+package github
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+func collectStream(t *testing.T, body string) ([]provider.GenerateResponse, error) {
+	t.Helper()
+
+	responseChan := make(chan provider.GenerateResponse)
+	errChan := make(chan error, 1)
+
+	go func() {
+		errChan <- readStream(context.Background(), strings.NewReader(body), responseChan)
+		close(responseChan)
+	}()
+
+	var responses []provider.GenerateResponse
+	for r := range responseChan {
+		responses = append(responses, r)
+	}
+
+	return responses, <-errChan
+}
+
+func TestReadStream(t *testing.T) {
+	body := `data: {"choices":[],"prompt_filter_results":[]}
+
+data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"}}],"model":"gpt-4o"}
+
+data: {"choices":[{"index":0,"delta":{"content":" world"}}],"model":"gpt-4o"}
+
+data: {"choices":[{"index":0,"delta":{"content":null},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}
+
+data: [DONE]
+
+`
+	responses, err := collectStream(t, body)
+	if err != nil {
+		t.Fatalf("readStream failed: %v", err)
+	}
+
+	if len(responses) != 3 {
+		t.Fatalf("expected 3 responses, got %d", len(responses))
+	}
+
+	if responses[0].Response != "Hello" || responses[1].Response != " world" {
+		t.Fatalf("unexpected content: %q, %q", responses[0].Response, responses[1].Response)
+	}
+
+	last := responses[2]
+	if !last.Done || last.Model != "gpt-4o" {
+		t.Fatalf("unexpected final response: %+v", last)
+	}
+	if last.Usage == nil || last.Usage.PromptTokens != 5 || last.Usage.CompletionTokens != 2 || last.Usage.TotalTokens != 7 {
+		t.Fatalf("unexpected usage: %+v", last.Usage)
+	}
+}
+
+func TestReadStreamTruncated(t *testing.T) {
+	body := `data: {"choices":[{"index":0,"delta":{"content":"Hel"}}]}
+`
+	responses, err := collectStream(t, body)
+	if err == nil {
+		t.Fatal("expected error for truncated stream")
+	}
+	if len(responses) != 1 {
+		t.Fatalf("expected the partial response to be forwarded, got %d responses", len(responses))
+	}
+}
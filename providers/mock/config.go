@@ -0,0 +1,72 @@
+package mock
+
+import (
+	"fmt"
+
+	"github.com/mcnull/qai/shared/provider"
+	"github.com/mcnull/qai/shared/utils"
+)
+
+type Config struct {
+	Model string `json:"model"`
+	// Fixed response, the prompt is echoed when empty and no responses file matches
+	Response string `json:"response,omitempty"`
+	// Path of a JSON file with canned responses keyed by a regular expression
+	Responses string `json:"responses,omitempty"`
+	// Number of characters per chunk when streaming
+	ChunkSize int `json:"chunk_size,omitempty"`
+	// Delay before every chunk
+	Delay *utils.Duration `json:"delay,omitempty"`
+	// Injects an error instead of the chunk with this index, 0 fails before any output
+	ErrorAt *int `json:"error_at,omitempty"`
+	// Class of the injected error: auth, rate_limit, connection, timeout, request or server
+	Error        string `json:"error,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+func NewConfig() provider.IConfig {
+	return &Config{
+		Model: DEFAULT_MODEL,
+	}
+}
+
+func (c *Config) Merge(other provider.IConfig) error {
+	o, ok := other.(*Config)
+	if !ok {
+		return fmt.Errorf("type mismatch: expected *Config, got %T", other)
+	}
+
+	if o.Model != DEFAULT_MODEL {
+		c.Model = o.Model
+	}
+
+	if o.Response != "" {
+		c.Response = o.Response
+	}
+
+	if o.Responses != "" {
+		c.Responses = o.Responses
+	}
+
+	if o.ChunkSize != 0 {
+		c.ChunkSize = o.ChunkSize
+	}
+
+	if o.Delay != nil {
+		c.Delay = o.Delay
+	}
+
+	if o.ErrorAt != nil {
+		c.ErrorAt = o.ErrorAt
+	}
+
+	if o.Error != "" {
+		c.Error = o.Error
+	}
+
+	if o.ErrorMessage != "" {
+		c.ErrorMessage = o.ErrorMessage
+	}
+
+	return nil
+}
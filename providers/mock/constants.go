@@ -0,0 +1,8 @@
+package mock
+
+const (
+	PROVIDER_NAME      = "mock"
+	DEFAULT_MODEL      = "mock"
+	DEFAULT_CHUNK_SIZE = 8
+	DEFAULT_ERROR      = "server"
+)
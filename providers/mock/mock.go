@@ -0,0 +1,198 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+// MockProvider answers without a model: it echoes the prompt or returns canned
+// responses, optionally slowly and with injected errors. It is meant for testing
+// scripts and wrappers around qai.
+type MockProvider struct {
+	provider.ProviderBase
+	config    Config
+	responses []cannedResponse
+}
+
+func NewMockProvider(config provider.IConfig, appCtx *provider.AppContext) (provider.IProvider, error) {
+
+	if config == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+
+	cfg, ok := config.(*Config)
+	if !ok {
+		return nil, fmt.Errorf("type mismatch: expected *Config, got %T", config)
+	}
+
+	p := &MockProvider{
+		config: *cfg,
+	}
+
+	p.ProviderBase = *provider.NewProviderBase(PROVIDER_NAME, appCtx)
+
+	return p, nil
+}
+
+func (p *MockProvider) Init() error {
+	if p.config.Error != "" {
+		if _, ok := provider.ParseErrorClass(p.config.Error); !ok {
+			return fmt.Errorf("unknown error class %q", p.config.Error)
+		}
+	}
+
+	if p.config.Responses == "" {
+		return nil
+	}
+
+	responses, err := loadResponses(p.config.Responses)
+	if err != nil {
+		return fmt.Errorf("error loading mock responses: %w", err)
+	}
+
+	p.responses = responses
+
+	return nil
+}
+
+func (p *MockProvider) Generate(ctx context.Context, request provider.GenerateRequest) (<-chan provider.GenerateResponse, <-chan error) {
+	responseChan := make(chan provider.GenerateResponse)
+	errorChan := make(chan error, 1)
+
+	go func() {
+		defer close(responseChan)
+		defer close(errorChan)
+
+		start := time.Now()
+
+		text := p.answer(request.Prompt)
+
+		chunks := []string{text}
+		if request.Stream {
+			chunks = split(text, p.chunkSize())
+		}
+
+		for i, chunk := range chunks {
+			if err := p.wait(ctx); err != nil {
+				errorChan <- err
+				return
+			}
+
+			if p.config.ErrorAt != nil && *p.config.ErrorAt == i {
+				errorChan <- p.injectedError()
+				return
+			}
+
+			select {
+			case responseChan <- provider.GenerateResponse{Raw: chunk, Response: chunk, Model: p.config.Model}:
+			case <-ctx.Done():
+				errorChan <- ctx.Err()
+				return
+			}
+		}
+
+		// An error after the last chunk fails the request before it is done
+		if p.config.ErrorAt != nil && *p.config.ErrorAt >= len(chunks) {
+			errorChan <- p.injectedError()
+			return
+		}
+
+		done := provider.GenerateResponse{
+			Done:  true,
+			Model: p.config.Model,
+			Usage: &provider.Usage{
+				PromptTokens:     len(strings.Fields(request.System + " " + request.Prompt)),
+				CompletionTokens: len(chunks),
+				TotalDuration:    time.Since(start),
+			},
+		}
+		done.Usage.TotalTokens = done.Usage.PromptTokens + done.Usage.CompletionTokens
+
+		select {
+		case responseChan <- done:
+		case <-ctx.Done():
+			errorChan <- ctx.Err()
+		}
+	}()
+
+	return responseChan, errorChan
+}
+
+// answer returns the fixed response, the first matching canned response or the prompt itself.
+func (p *MockProvider) answer(prompt string) string {
+	if p.config.Response != "" {
+		return p.config.Response
+	}
+
+	if text, ok := findResponse(p.responses, prompt); ok {
+		return text
+	}
+
+	return prompt
+}
+
+func (p *MockProvider) chunkSize() int {
+	if p.config.ChunkSize > 0 {
+		return p.config.ChunkSize
+	}
+	return DEFAULT_CHUNK_SIZE
+}
+
+// wait sleeps for the configured delay or until ctx is done.
+func (p *MockProvider) wait(ctx context.Context) error {
+	if p.config.Delay == nil || p.config.Delay.Duration() <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(p.config.Delay.Duration())
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (p *MockProvider) injectedError() *provider.Error {
+	name := p.config.Error
+	if name == "" {
+		name = DEFAULT_ERROR
+	}
+
+	class, _ := provider.ParseErrorClass(name)
+
+	message := p.config.ErrorMessage
+	if message == "" {
+		message = "injected " + name + " error"
+	}
+
+	return &provider.Error{
+		Provider:  PROVIDER_NAME,
+		Class:     class,
+		Message:   message,
+		Retryable: class == provider.ErrorClassRateLimit || class == provider.ErrorClassServer,
+	}
+}
+
+// split cuts text into chunks of size characters.
+func split(text string, size int) []string {
+	runes := []rune(text)
+
+	var chunks []string
+	for len(runes) > size {
+		chunks = append(chunks, string(runes[:size]))
+		runes = runes[size:]
+	}
+
+	if len(runes) > 0 {
+		chunks = append(chunks, string(runes))
+	}
+
+	return chunks
+}
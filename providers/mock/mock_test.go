@@ -0,0 +1,158 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcnull/qai/shared/provider"
+	"github.com/mcnull/qai/shared/utils"
+)
+
+func newTestProvider(t *testing.T, cfg *Config) *MockProvider {
+	t.Helper()
+
+	if cfg.Model == "" {
+		cfg.Model = DEFAULT_MODEL
+	}
+
+	p, err := NewMockProvider(cfg, &provider.AppContext{})
+	if err != nil {
+		t.Fatalf("NewMockProvider failed: %v", err)
+	}
+
+	if err := p.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	return p.(*MockProvider)
+}
+
+// collect reads all responses and the final error of a Generate call.
+func collect(responses <-chan provider.GenerateResponse, errs <-chan error) ([]provider.GenerateResponse, error) {
+	var result []provider.GenerateResponse
+
+	for resp := range responses {
+		result = append(result, resp)
+	}
+
+	return result, <-errs
+}
+
+func text(responses []provider.GenerateResponse) string {
+	var b strings.Builder
+	for _, r := range responses {
+		b.WriteString(r.Response)
+	}
+	return b.String()
+}
+
+func TestEcho(t *testing.T) {
+	p := newTestProvider(t, &Config{ChunkSize: 3})
+
+	responses, err := collect(p.Generate(context.Background(), provider.GenerateRequest{Prompt: "hello world", Stream: true}))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	// "hel", "lo ", "wor", "ld" and the final response
+	if len(responses) != 5 || text(responses) != "hello world" {
+		t.Fatalf("unexpected responses: %+v", responses)
+	}
+
+	last := responses[len(responses)-1]
+	if !last.Done || last.Model != DEFAULT_MODEL || last.Usage == nil || last.Usage.CompletionTokens != 4 {
+		t.Fatalf("unexpected final response: %+v", last)
+	}
+}
+
+func TestNoStreamSendsOneChunk(t *testing.T) {
+	p := newTestProvider(t, &Config{ChunkSize: 1, Response: "fixed answer"})
+
+	responses, err := collect(p.Generate(context.Background(), provider.GenerateRequest{Prompt: "anything"}))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if len(responses) != 2 || responses[0].Response != "fixed answer" {
+		t.Fatalf("unexpected responses: %+v", responses)
+	}
+}
+
+func TestCannedResponses(t *testing.T) {
+	p := newTestProvider(t, &Config{Responses: "testdata/responses.json"})
+
+	tests := map[string]string{
+		"How do I LIST FILES?":  "Use `ls -la`.",
+		"disk usage":            "Use `df -h`.",
+		"no match, echo please": "no match, echo please",
+	}
+
+	for prompt, want := range tests {
+		responses, err := collect(p.Generate(context.Background(), provider.GenerateRequest{Prompt: prompt, Stream: true}))
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+
+		if got := text(responses); got != want {
+			t.Fatalf("prompt %q: got %q, want %q", prompt, got, want)
+		}
+	}
+}
+
+func TestInvalidResponsesFile(t *testing.T) {
+	p, _ := NewMockProvider(&Config{Model: DEFAULT_MODEL, Responses: "testdata/missing.json"}, &provider.AppContext{})
+
+	if err := p.Init(); err == nil {
+		t.Fatal("expected error for missing responses file")
+	}
+
+	p, _ = NewMockProvider(&Config{Model: DEFAULT_MODEL, Error: "oops"}, &provider.AppContext{})
+
+	if err := p.Init(); err == nil {
+		t.Fatal("expected error for unknown error class")
+	}
+}
+
+func TestErrorInjection(t *testing.T) {
+	errorAt := 2
+	p := newTestProvider(t, &Config{ChunkSize: 2, ErrorAt: &errorAt, Error: "rate_limit"})
+
+	responses, err := collect(p.Generate(context.Background(), provider.GenerateRequest{Prompt: "abcdefgh", Stream: true}))
+
+	perr, ok := provider.AsError(err)
+	if !ok || perr.Class != provider.ErrorClassRateLimit || !perr.Retryable {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+
+	if text(responses) != "abcd" {
+		t.Fatalf("expected two chunks before the error, got %+v", responses)
+	}
+}
+
+func TestErrorBeforeOutput(t *testing.T) {
+	errorAt := 0
+	p := newTestProvider(t, &Config{ErrorAt: &errorAt, Error: "connection", ErrorMessage: "no route"})
+
+	responses, err := collect(p.Generate(context.Background(), provider.GenerateRequest{Prompt: "hi", Stream: true}))
+
+	perr, ok := provider.AsError(err)
+	if !ok || perr.Class != provider.ErrorClassConnection || perr.Message != "no route" || len(responses) != 0 {
+		t.Fatalf("unexpected result: %+v, %v", responses, err)
+	}
+}
+
+func TestDelayAndCancel(t *testing.T) {
+	delay := utils.Duration(time.Hour)
+	p := newTestProvider(t, &Config{Delay: &delay})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	_, err := collect(p.Generate(ctx, provider.GenerateRequest{Prompt: "hi", Stream: true}))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
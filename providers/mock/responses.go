@@ -0,0 +1,49 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// cannedResponse answers every prompt matching the expression.
+type cannedResponse struct {
+	Match    string `json:"match"`
+	Response string `json:"response"`
+
+	re *regexp.Regexp
+}
+
+// loadResponses reads a JSON array of {"match": "regex", "response": "text"} entries.
+func loadResponses(path string) ([]cannedResponse, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []cannedResponse
+	if err := json.Unmarshal(data, &responses); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	for i := range responses {
+		re, err := regexp.Compile(responses[i].Match)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: entry %d: %w", path, i, err)
+		}
+		responses[i].re = re
+	}
+
+	return responses, nil
+}
+
+// findResponse returns the response of the first entry matching prompt.
+func findResponse(responses []cannedResponse, prompt string) (string, bool) {
+	for _, r := range responses {
+		if r.re.MatchString(prompt) {
+			return r.Response, true
+		}
+	}
+	return "", false
+}
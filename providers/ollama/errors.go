@@ -0,0 +1,40 @@
+package ollama
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/mcnull/qai/shared/provider"
+)
+
+const PROVIDER_NAME = "ollama"
+
+// maxErrorBody limits how much of an error response is read.
+const maxErrorBody = 64 * 1024
+
+// newStatusError creates a provider error from a non-successful ollama response.
+func (p *OllamaProvider) newStatusError(resp *http.Response) *provider.Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	e := provider.NewStatusError(PROVIDER_NAME, resp.StatusCode, body)
+
+	if resp.StatusCode == http.StatusNotFound && strings.Contains(e.Message, "not found") {
+		e.Hint = fmt.Sprintf("run `ollama pull %s` to download the model", p.config.Model)
+	}
+
+	return e
+}
+
+// newRequestError creates a provider error for a request that did not get a response.
+func (p *OllamaProvider) newRequestError(err error) *provider.Error {
+	e := provider.NewRequestError(PROVIDER_NAME, err)
+
+	if provider.IsConnectionRefused(err) {
+		e.Message = fmt.Sprintf("unable to connect to %s", p.config.URL)
+		e.Hint = "is `ollama serve` running?"
+	}
+
+	return e
+}
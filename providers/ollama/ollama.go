@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/mcnull/qai/shared/provider"
 )
@@ -31,7 +32,7 @@ func NewOllamaProvider(config provider.IConfig, appCtx *provider.AppContext) (pr
 		config: *cfg,
 	}
 
-	p.ProviderBase = *provider.NewProviderBase("ollama", appCtx)
+	p.ProviderBase = *provider.NewProviderBase(PROVIDER_NAME, appCtx)
 
 	return p, nil
 }
@@ -49,14 +50,14 @@ func (p *OllamaProvider) Generate(ctx context.Context, request provider.Generate
 			Model:  p.config.Model,
 			Prompt: request.Prompt,
 			System: request.System,
-			Stream: !p.Flags().Color,
+			Stream: request.Stream,
 			Options: &Options{
 				Seed: p.config.Seed,
 			},
+			Format: request.Schema,
 		}
 
-		// Set up custom HTTP client to get raw response instead of using the library's scanner
-		client := &http.Client{}
+		client := p.HTTPClient()
 		jsonData, err := json.Marshal(ollamaReq)
 		if err != nil {
 			errorChan <- fmt.Errorf("error marshaling request: %w", err)
@@ -76,15 +77,14 @@ func (p *OllamaProvider) Generate(ctx context.Context, request provider.Generate
 		// Send request
 		resp, err := client.Do(req)
 		if err != nil {
-			errorChan <- fmt.Errorf("error sending request: %w", err)
+			errorChan <- p.newRequestError(err)
 			return
 		}
 		defer resp.Body.Close()
 
 		// Check for non-200 status code
 		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			errorChan <- fmt.Errorf("error response from server: %s", body)
+			errorChan <- p.newStatusError(resp)
 			return
 		}
 
@@ -93,7 +93,13 @@ func (p *OllamaProvider) Generate(ctx context.Context, request provider.Generate
 		for {
 			var rawMessage json.RawMessage
 			if err := decoder.Decode(&rawMessage); err != nil {
-				if err == io.EOF {
+				if ctx.Err() != nil {
+					errorChan <- ctx.Err()
+					return
+				}
+				if err == io.EOF || err == io.ErrUnexpectedEOF {
+					// The final response, marked done, never arrived
+					errorChan <- fmt.Errorf("stream ended unexpectedly")
 					return
 				}
 				errorChan <- fmt.Errorf("error decoding response: %w", err)
@@ -106,10 +112,33 @@ func (p *OllamaProvider) Generate(ctx context.Context, request provider.Generate
 				return
 			}
 
+			// Errors that occur after the response has started are sent in the stream
+			if ollamaResp.Error != "" {
+				errorChan <- &provider.Error{
+					Provider: PROVIDER_NAME,
+					Class:    provider.ErrorClassServer,
+					Message:  ollamaResp.Error,
+				}
+				return
+			}
+
 			providerResp := provider.GenerateResponse{
 				Raw:      rawMessage,
 				Response: ollamaResp.Response,
 				Done:     ollamaResp.Done,
+				Model:    ollamaResp.Model,
+			}
+
+			if ollamaResp.Done {
+				providerResp.Usage = &provider.Usage{
+					PromptTokens:     ollamaResp.PromptEvalCount,
+					CompletionTokens: ollamaResp.EvalCount,
+					TotalTokens:      ollamaResp.PromptEvalCount + ollamaResp.EvalCount,
+					TotalDuration:    time.Duration(ollamaResp.TotalDuration),
+					LoadDuration:     time.Duration(ollamaResp.LoadDuration),
+					PromptDuration:   time.Duration(ollamaResp.PromptEvalDuration),
+					EvalDuration:     time.Duration(ollamaResp.EvalDuration),
+				}
 			}
 
 			select {
@@ -127,3 +156,8 @@ func (p *OllamaProvider) Generate(ctx context.Context, request provider.Generate
 
 	return responseChan, errorChan
 }
+
+// EnforcesSchema reports that the schema of a request is sent as the format of the answer.
+func (p *OllamaProvider) EnforcesSchema() bool {
+	return true
+}
@@ -0,0 +1,135 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcnull/qai/shared/cassette"
+	"github.com/mcnull/qai/shared/httpclient"
+	"github.com/mcnull/qai/shared/provider"
+)
+
+// newTestProvider returns a provider whose requests are answered from the cassette testdata/<name>.json.
+func newTestProvider(t *testing.T, name string) (*OllamaProvider, *cassette.Replayer) {
+	t.Helper()
+
+	replayer, err := cassette.LoadReplayer(filepath.Join("testdata", name+".json"))
+	if err != nil {
+		t.Fatalf("error loading cassette: %v", err)
+	}
+
+	appCtx := &provider.AppContext{
+		HTTPClient: httpclient.New(0).WithTransport(replayer),
+	}
+
+	p, err := NewOllamaProvider(NewConfig(), appCtx)
+	if err != nil {
+		t.Fatalf("NewOllamaProvider failed: %v", err)
+	}
+
+	return p.(*OllamaProvider), replayer
+}
+
+// collect reads all responses and the final error of a Generate call.
+func collect(responses <-chan provider.GenerateResponse, errs <-chan error) ([]provider.GenerateResponse, error) {
+	var result []provider.GenerateResponse
+
+	for resp := range responses {
+		result = append(result, resp)
+	}
+
+	return result, <-errs
+}
+
+func TestGenerateStream(t *testing.T) {
+	p, replayer := newTestProvider(t, "generate")
+
+	responses, err := collect(p.Generate(context.Background(), provider.GenerateRequest{
+		System: "Be brief.",
+		Prompt: "list files",
+		Stream: true,
+	}))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	var text strings.Builder
+	for _, r := range responses {
+		text.WriteString(r.Response)
+	}
+
+	if text.String() != "Use `ls -la`." {
+		t.Fatalf("unexpected response: %q", text.String())
+	}
+
+	last := responses[len(responses)-1]
+	if !last.Done || last.Model != "llama3.2" {
+		t.Fatalf("unexpected final response: %+v", last)
+	}
+
+	if last.Usage == nil || last.Usage.PromptTokens != 30 || last.Usage.CompletionTokens != 12 || last.Usage.EvalDuration != 400*time.Millisecond {
+		t.Fatalf("unexpected usage: %+v", last.Usage)
+	}
+
+	if replayer.Remaining() != 0 {
+		t.Fatalf("expected all interactions to be replayed, %d remaining", replayer.Remaining())
+	}
+}
+
+func TestGenerateModelNotFound(t *testing.T) {
+	p, _ := newTestProvider(t, "model_not_found")
+
+	_, err := collect(p.Generate(context.Background(), provider.GenerateRequest{Prompt: "hi", Stream: true}))
+
+	perr, ok := provider.AsError(err)
+	if !ok {
+		t.Fatalf("expected provider error, got %v", err)
+	}
+
+	if perr.Class != provider.ErrorClassRequest || perr.Status != 404 {
+		t.Fatalf("unexpected error: %+v", perr)
+	}
+
+	if !strings.Contains(perr.Message, "not found") || !strings.Contains(perr.Hint, "ollama pull llama3.2") {
+		t.Fatalf("unexpected message or hint: %+v", perr)
+	}
+}
+
+func TestGenerateTruncatedStream(t *testing.T) {
+	p, _ := newTestProvider(t, "truncated")
+
+	responses, err := collect(p.Generate(context.Background(), provider.GenerateRequest{Prompt: "hi", Stream: true}))
+
+	if err == nil || !strings.Contains(err.Error(), "stream ended unexpectedly") {
+		t.Fatalf("expected truncated stream error, got %v", err)
+	}
+
+	if len(responses) != 1 || responses[0].Response != "Use " {
+		t.Fatalf("expected the partial response before the error, got %+v", responses)
+	}
+}
+
+func TestGenerateCancelled(t *testing.T) {
+	p, _ := newTestProvider(t, "slow")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	responseChan, errorChan := p.Generate(ctx, provider.GenerateRequest{Prompt: "hi", Stream: true})
+
+	first, ok := <-responseChan
+	if !ok || first.Response != "Use " {
+		t.Fatalf("unexpected first response: %+v", first)
+	}
+
+	cancel()
+
+	_, err := collect(responseChan, errorChan)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -1,16 +1,18 @@
 package ollama
 
+import "encoding/json"
+
 type GenerateRequest struct {
-	Model     string   `json:"model"`
-	Prompt    string   `json:"prompt,omitempty"`
-	System    string   `json:"system,omitempty"`
-	Template  string   `json:"template,omitempty"`
-	Context   []int    `json:"context,omitempty"`
-	Stream    bool     `json:"stream"`
-	Raw       bool     `json:"raw,omitempty"`
-	Format    string   `json:"format,omitempty"`
-	Options   *Options `json:"options,omitempty"`
-	KeepAlive string   `json:"keep_alive,omitempty"`
+	Model     string          `json:"model"`
+	Prompt    string          `json:"prompt,omitempty"`
+	System    string          `json:"system,omitempty"`
+	Template  string          `json:"template,omitempty"`
+	Context   []int           `json:"context,omitempty"`
+	Stream    bool            `json:"stream"`
+	Raw       bool            `json:"raw,omitempty"`
+	Format    json.RawMessage `json:"format,omitempty"`
+	Options   *Options        `json:"options,omitempty"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
 }
 
 type Options struct {
@@ -40,14 +42,16 @@ type Options struct {
 }
 
 type GenerateResponse struct {
-	Model           string `json:"model,omitempty"`
-	CreatedAt       string `json:"created_at,omitempty"`
-	Response        string `json:"response"`
-	Done            bool   `json:"done,omitempty"`
-	Context         []int  `json:"context,omitempty"`
-	TotalDuration   int64  `json:"total_duration,omitempty"`
-	LoadDuration    int64  `json:"load_duration,omitempty"`
-	PromptEvalCount int    `json:"prompt_eval_count,omitempty"`
-	EvalCount       int    `json:"eval_count,omitempty"`
-	EvalDuration    int64  `json:"eval_duration,omitempty"`
+	Model              string `json:"model,omitempty"`
+	CreatedAt          string `json:"created_at,omitempty"`
+	Response           string `json:"response"`
+	Done               bool   `json:"done,omitempty"`
+	Context            []int  `json:"context,omitempty"`
+	TotalDuration      int64  `json:"total_duration,omitempty"`
+	LoadDuration       int64  `json:"load_duration,omitempty"`
+	PromptEvalCount    int    `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64  `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int    `json:"eval_count,omitempty"`
+	EvalDuration       int64  `json:"eval_duration,omitempty"`
+	Error              string `json:"error,omitempty"`
 }
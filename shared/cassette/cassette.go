@@ -0,0 +1,61 @@
+package cassette
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/mcnull/qai/shared/utils"
+)
+
+// Cassette is a recording of HTTP interactions, in the order they happened.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"headers,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response holds the body as the chunks in which it was read, so streamed
+// responses are replayed the way they arrived.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"headers,omitempty"`
+	Chunks []string    `json:"chunks"`
+	// Delay before each chunk when replaying, to simulate slow responses
+	ChunkDelay utils.Duration `json:"chunk_delay,omitempty"`
+}
+
+// Load reads a cassette file.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Save writes the cassette to path.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
@@ -0,0 +1,184 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcnull/qai/shared/utils"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"answer":"hello","token":"secret"}`)
+	}))
+	defer srv.Close()
+
+	recorder, err := NewRecorder(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+
+	req, _ := http.NewRequest("POST", srv.URL+"/api/generate", strings.NewReader(`{"prompt":"hi"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+
+	resp, err := (&http.Client{Transport: recorder}).Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	c, err := Load(recorder.Path())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(c.Interactions) != 1 {
+		t.Fatalf("expected 1 interaction, got %d", len(c.Interactions))
+	}
+
+	recorded := c.Interactions[0]
+
+	if recorded.Request.Body != `{"prompt":"hi"}` || recorded.Response.Status != 200 {
+		t.Fatalf("unexpected interaction: %+v", recorded)
+	}
+
+	if recorded.Request.Header.Get("Authorization") == "Bearer secret" {
+		t.Fatal("authorization header was recorded")
+	}
+
+	body := strings.Join(recorded.Response.Chunks, "")
+	if !strings.Contains(body, `"answer":"hello"`) || strings.Contains(body, "secret") {
+		t.Fatalf("unexpected recorded body: %s", body)
+	}
+
+	// Replay against another host
+	replayer := NewReplayer(c)
+
+	req, _ = http.NewRequest("POST", "http://other.example/api/generate", strings.NewReader(`{}`))
+	resp, err = (&http.Client{Transport: replayer}).Do(req)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+
+	replayed, _ := io.ReadAll(resp.Body)
+	if string(replayed) != body || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected replayed response: %s", replayed)
+	}
+
+	if replayer.Remaining() != 0 {
+		t.Fatalf("expected all interactions to be replayed, %d remaining", replayer.Remaining())
+	}
+
+	if _, err := (&http.Client{Transport: replayer}).Do(req); err == nil {
+		t.Fatal("expected error once the cassette is exhausted")
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	replayer := NewReplayer(&Cassette{Interactions: []Interaction{
+		{Request: Request{Method: "GET", URL: "http://localhost/a"}, Response: Response{Status: 200}},
+	}})
+
+	req, _ := http.NewRequest("GET", "http://localhost/b", nil)
+
+	if _, err := replayer.RoundTrip(req); err == nil || !strings.Contains(err.Error(), "expected request GET /a") {
+		t.Fatalf("expected mismatch error, got %v", err)
+	}
+}
+
+func TestReplayCancelled(t *testing.T) {
+	replayer := NewReplayer(&Cassette{Interactions: []Interaction{
+		{
+			Request:  Request{Method: "GET", URL: "http://localhost/stream"},
+			Response: Response{Status: 200, Chunks: []string{"a", "b"}, ChunkDelay: utils.Duration(time.Hour)},
+		},
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://localhost/stream", nil)
+
+	resp, err := replayer.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRecordStreamedSecret(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, chunk := range []string{"data: {\"text\":\"a\"}\n", `data: {"text":"ghp_0123456789`, "abcdefgh\"}\n"} {
+			fmt.Fprint(w, chunk)
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	recorder, err := NewRecorder(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+
+	resp, err := (&http.Client{Transport: recorder}).Get(srv.URL + "/stream")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	io.ReadAll(resp.Body)
+
+	// The body is only written once it is closed
+	if c, err := Load(recorder.Path()); err != nil || len(c.Interactions) != 1 || len(c.Interactions[0].Response.Chunks) != 0 {
+		t.Fatalf("expected the interaction without its body, got %+v (%v)", c, err)
+	}
+
+	resp.Body.Close()
+
+	c, err := Load(recorder.Path())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	body := strings.Join(c.Interactions[0].Response.Chunks, "")
+	if strings.Contains(body, "0123456789") || body != "data: {\"text\":\"a\"}\ndata: {\"text\":\"[REDACTED]\"}\n" {
+		t.Fatalf("unexpected recorded body %q", body)
+	}
+}
+
+func TestRedactChunks(t *testing.T) {
+	chunks := redactChunks([]string{"x ghp_0123456789", "abcdefgh y", "z"})
+
+	if len(chunks) != 3 || strings.Join(chunks, "") != "x [REDACTED] yz" {
+		t.Fatalf("unexpected chunks %q", chunks)
+	}
+
+	for _, chunk := range chunks {
+		if strings.Contains(chunk, "0123456789") {
+			t.Fatalf("secret recorded in %q", chunks)
+		}
+	}
+}
+
+func TestReplayModelMismatch(t *testing.T) {
+	replayer := NewReplayer(&Cassette{Interactions: []Interaction{
+		{Request: Request{Method: "POST", URL: "http://localhost/api/generate", Body: `{"model":"llama3.2","prompt":"hi"}`}, Response: Response{Status: 200}},
+	}})
+
+	req, _ := http.NewRequest("POST", "http://localhost/api/generate", strings.NewReader(`{"model":"qwen3","prompt":"hi"}`))
+
+	if _, err := replayer.RoundTrip(req); err == nil || !strings.Contains(err.Error(), `expected model "llama3.2"`) {
+		t.Fatalf("expected model mismatch error, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package cassette
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mcnull/qai/shared/logging"
+)
+
+// Recorder is a transport that saves every interaction to a cassette file.
+// Secrets are redacted before anything is written.
+type Recorder struct {
+	next     http.RoundTripper
+	path     string
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder creates a recorder writing a new cassette in dir. next defaults to
+// http.DefaultTransport.
+func NewRecorder(dir string, next http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating cassette directory: %w", err)
+	}
+
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	name := time.Now().Format("20060102-150405.000000") + ".json"
+
+	return &Recorder{
+		next: next,
+		path: filepath.Join(dir, name),
+	}, nil
+}
+
+// Path returns the path of the cassette file.
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded := Request{
+		Method: req.Method,
+		URL:    logging.Redact(req.URL.String()),
+		Header: logging.RedactHeader(req.Header),
+	}
+
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			body.Close()
+			recorded.Body = logging.Redact(string(data))
+		}
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: recorded,
+		Response: Response{
+			Status: resp.StatusCode,
+			Header: logging.RedactHeader(resp.Header),
+			Chunks: []string{},
+		},
+	})
+	index := len(r.cassette.Interactions) - 1
+	r.saveLocked()
+	r.mu.Unlock()
+
+	resp.Body = &recordedBody{ReadCloser: resp.Body, recorder: r, index: index}
+
+	return resp, nil
+}
+
+// setChunks records the body of a response once it is closed. The body is redacted as a
+// whole, so that a secret split across chunks is found.
+func (r *Recorder) setChunks(index int, chunks []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions[index].Response.Chunks = redactChunks(chunks)
+
+	r.saveLocked()
+}
+
+func (r *Recorder) saveLocked() {
+	// Recording is a debugging aid, it must never break the request
+	_ = r.cassette.Save(r.path)
+}
+
+// redactChunks redacts the chunks of a body and splits the result again close to the
+// original chunk boundaries, so streamed responses keep their chunks when replayed.
+func redactChunks(chunks []string) []string {
+	body := logging.Redact(strings.Join(chunks, ""))
+	redacted := make([]string, 0, len(chunks))
+
+	var read strings.Builder
+	start := 0
+
+	for i, chunk := range chunks {
+		read.WriteString(chunk)
+
+		end := len(body)
+		if i < len(chunks)-1 {
+			end = min(max(len(logging.Redact(read.String())), start), len(body))
+		}
+
+		redacted = append(redacted, body[start:end])
+		start = end
+	}
+
+	return redacted
+}
+
+// recordedBody collects the chunks of a response body as they are read and records
+// them when the body is closed.
+type recordedBody struct {
+	io.ReadCloser
+	recorder *Recorder
+	index    int
+	chunks   []string
+	once     sync.Once
+}
+
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	if n > 0 {
+		b.chunks = append(b.chunks, string(p[:n]))
+	}
+
+	return n, err
+}
+
+func (b *recordedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.recorder.setChunks(b.index, b.chunks) })
+	return err
+}
@@ -0,0 +1,181 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Replayer is a transport that answers requests from a cassette instead of the network.
+// Interactions are replayed in order; the method, the path and the model in the JSON body
+// of every request must match the recording, when it has one. The host is ignored, so a cassette works
+// with any base URL.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	next         int
+}
+
+// NewReplayer creates a replayer for the interactions of c.
+func NewReplayer(c *Cassette) *Replayer {
+	return &Replayer{interactions: c.Interactions}
+}
+
+// LoadReplayer creates a replayer for the cassette file at path.
+func LoadReplayer(path string) (*Replayer, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading cassette: %w", err)
+	}
+
+	return NewReplayer(c), nil
+}
+
+// Remaining returns the number of interactions that have not been replayed.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.interactions) - r.next
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+
+	if r.next >= len(r.interactions) {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("cassette: unexpected request %s %s, all interactions have been replayed", req.Method, req.URL)
+	}
+
+	interaction := r.interactions[r.next]
+	r.next++
+	r.mu.Unlock()
+
+	if err := match(interaction.Request, req); err != nil {
+		return nil, err
+	}
+
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	resp := interaction.Response
+
+	header := resp.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", resp.Status, http.StatusText(resp.Status)),
+		StatusCode: resp.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body: &replayBody{
+			ctx:    req.Context(),
+			chunks: resp.Chunks,
+			delay:  resp.ChunkDelay.Duration(),
+		},
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+func match(recorded Request, req *http.Request) error {
+	u, err := url.Parse(recorded.URL)
+	if err != nil {
+		return fmt.Errorf("cassette: invalid recorded url %q: %w", recorded.URL, err)
+	}
+
+	if !strings.EqualFold(recorded.Method, req.Method) || u.Path != req.URL.Path {
+		return fmt.Errorf("cassette: expected request %s %s, got %s %s", recorded.Method, u.Path, req.Method, req.URL.Path)
+	}
+
+	// The rest of the body, such as the prompt, may differ between runs. Hand-written
+	// interactions without a body match any model.
+	if want := bodyModel(recorded.Body); want != "" {
+		if got := requestModel(req); got != want {
+			return fmt.Errorf("cassette: expected model %q for %s %s, got %q", want, req.Method, u.Path, got)
+		}
+	}
+
+	return nil
+}
+
+// requestModel returns the model of the JSON body of req, leaving the body readable.
+func requestModel(req *http.Request) string {
+	if req.Body == nil || req.Body == http.NoBody {
+		return ""
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(data))
+
+	if err != nil {
+		return ""
+	}
+
+	return bodyModel(string(data))
+}
+
+// bodyModel returns the "model" field of a JSON request body, or "" when it has none.
+func bodyModel(body string) string {
+	var v struct {
+		Model string `json:"model"`
+	}
+
+	if json.Unmarshal([]byte(body), &v) != nil {
+		return ""
+	}
+
+	return v.Model
+}
+
+// replayBody returns the recorded chunks one read at a time and honors the
+// cancellation of the request while waiting for the next chunk.
+type replayBody struct {
+	ctx     context.Context
+	chunks  []string
+	delay   time.Duration
+	pending string
+}
+
+func (b *replayBody) Read(p []byte) (int, error) {
+	if b.pending == "" {
+		if len(b.chunks) == 0 {
+			return 0, io.EOF
+		}
+
+		if b.delay > 0 {
+			timer := time.NewTimer(b.delay)
+			select {
+			case <-b.ctx.Done():
+				timer.Stop()
+				return 0, b.ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := b.ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		b.pending, b.chunks = b.chunks[0], b.chunks[1:]
+	}
+
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+
+	return n, nil
+}
+
+func (b *replayBody) Close() error {
+	return nil
+}
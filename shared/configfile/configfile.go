@@ -0,0 +1,213 @@
+// Package configfile reads and updates config files written as JSON with comments, YAML
+// or TOML, chosen by the file extension. Documents are exchanged as plain JSON, so the same
+// structs decode every format, and updates keep the comments of the file where the format allows.
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mcnull/qai/shared/jsonc"
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	FORMAT_JSON Format = "json"
+	FORMAT_YAML Format = "yaml"
+	FORMAT_TOML Format = "toml"
+)
+
+// EXTENSIONS are the supported file extensions, in the order config files are looked up.
+var EXTENSIONS = []string{".json", ".jsonc", ".yaml", ".yml", ".toml"}
+
+// FormatOf returns the format of the file at path. Files with an unknown extension are JSON.
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FORMAT_YAML
+	case ".toml":
+		return FORMAT_TOML
+	default:
+		return FORMAT_JSON
+	}
+}
+
+// File is a config document together with the text it was read from.
+type File struct {
+	format Format
+	data   []byte
+	json   []byte
+
+	// Used to find the position of values
+	locator *jsonc.Locator
+	node    *yaml.Node
+}
+
+// New returns an empty document in the given format.
+func New(format Format) *File {
+	return &File{format: format, json: []byte("{}")}
+}
+
+// Read reads the config file at path in the format of its extension.
+func Read(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data, FormatOf(path))
+}
+
+// Parse parses data in the given format. A syntax error is returned as a *jsonc.SyntaxError.
+func Parse(data []byte, format Format) (*File, error) {
+	f := &File{format: format, data: data}
+
+	var err error
+
+	switch format {
+	case FORMAT_YAML:
+		err = f.parseYAML()
+	case FORMAT_TOML:
+		err = f.parseTOML()
+	case FORMAT_JSON:
+		f.locator, err = jsonc.NewLocator(data)
+		if err == nil {
+			f.json = f.locator.JSON()
+		}
+	default:
+		err = fmt.Errorf("unsupported config format %q", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *File) Format() Format {
+	return f.format
+}
+
+// Bytes returns the text of the document.
+func (f *File) Bytes() []byte {
+	return f.data
+}
+
+// JSON returns the document as plain JSON. For JSON files the offsets match the original text.
+func (f *File) JSON() []byte {
+	return f.json
+}
+
+// Decode decodes the document into v.
+func (f *File) Decode(v any) error {
+	return json.Unmarshal(f.json, v)
+}
+
+// Position returns the position of the key holding the value at path, or of the value for
+// array items. It is zero when the format does not record positions.
+func (f *File) Position(path []string) jsonc.Position {
+	switch {
+	case f.locator != nil:
+		return f.locator.Key(path)
+	case f.node != nil:
+		return yamlPosition(f.node, path)
+	default:
+		return jsonc.Position{}
+	}
+}
+
+// Update changes the document to encode v. Comments and the order of existing keys are kept
+// in JSON and YAML files; TOML files are written anew.
+func (f *File) Update(v any) error {
+	var (
+		data []byte
+		err  error
+	)
+
+	switch {
+	case f.format == FORMAT_JSON && len(f.data) > 0:
+		data, err = jsonc.Patch(f.data, v)
+	case f.format == FORMAT_JSON:
+		data, err = marshalJSON(v)
+	case f.format == FORMAT_YAML:
+		data, err = f.updateYAML(v)
+	case f.format == FORMAT_TOML:
+		data, err = marshalTOML(v)
+	default:
+		err = fmt.Errorf("unsupported config format %q", f.format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	updated, err := Parse(data, f.format)
+	if err != nil {
+		return fmt.Errorf("error parsing the updated document: %w", err)
+	}
+
+	*f = *updated
+
+	return nil
+}
+
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// plainValue returns v as JSON values with numbers converted to int64 or float64,
+// as the YAML and TOML encoders expect.
+func plainValue(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	return convertNumbers(doc), nil
+}
+
+func convertNumbers(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, child := range value {
+			value[k] = convertNumbers(child)
+		}
+	case []any:
+		for i, child := range value {
+			value[i] = convertNumbers(child)
+		}
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	}
+
+	return v
+}
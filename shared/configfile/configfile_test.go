@@ -0,0 +1,221 @@
+package configfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mcnull/qai/shared/jsonc"
+)
+
+type testConfig struct {
+	Profile  string                    `json:"profile"`
+	Profiles map[string]map[string]any `json:"profiles"`
+	Empty    any                       `json:"empty"`
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]Format{
+		"config.json":  FORMAT_JSON,
+		"config.jsonc": FORMAT_JSON,
+		"config.yaml":  FORMAT_YAML,
+		"CONFIG.YML":   FORMAT_YAML,
+		"config.toml":  FORMAT_TOML,
+		"config":       FORMAT_JSON,
+	}
+
+	for path, want := range tests {
+		if got := FormatOf(path); got != want {
+			t.Fatalf("FormatOf(%q): expected %s, got %s", path, want, got)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	docs := map[Format]string{
+		FORMAT_JSON: `{
+  // The active profile
+  "profile": "work",
+  "profiles": {"work": {"retries": 3, "model": "small",},},
+}`,
+		FORMAT_YAML: `# The active profile
+profile: work
+profiles:
+  work:
+    retries: 3
+    model: small
+`,
+		FORMAT_TOML: `# The active profile
+profile = "work"
+
+[profiles.work]
+retries = 3
+model = "small"
+`,
+	}
+
+	for format, doc := range docs {
+		f, err := Parse([]byte(doc), format)
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", format, err)
+		}
+
+		var config testConfig
+		if err := f.Decode(&config); err != nil {
+			t.Fatalf("%s: Decode failed: %v", format, err)
+		}
+
+		if config.Profile != "work" || config.Profiles["work"]["model"] != "small" || config.Profiles["work"]["retries"] != 3.0 {
+			t.Fatalf("%s: unexpected config %+v", format, config)
+		}
+	}
+}
+
+func TestParseSyntaxError(t *testing.T) {
+	tests := []struct {
+		format Format
+		doc    string
+		want   jsonc.Position
+	}{
+		{FORMAT_JSON, "{\n  \"a\": 1\n  \"b\": 2\n}", jsonc.Position{Line: 3, Column: 3}},
+		{FORMAT_YAML, "a: 1\nb: c: 2\n", jsonc.Position{Line: 2}},
+		{FORMAT_TOML, "a = 1\nb = \n", jsonc.Position{Line: 2, Column: 5}},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.doc), tt.format)
+
+		var sErr *jsonc.SyntaxError
+		if !errors.As(err, &sErr) {
+			t.Fatalf("%s: expected a syntax error, got %v", tt.format, err)
+		}
+
+		if sErr.Position != tt.want {
+			t.Fatalf("%s: expected position %s, got %s (%s)", tt.format, tt.want, sErr.Position, sErr.Message)
+		}
+	}
+}
+
+func TestUpdateKeepsComments(t *testing.T) {
+	tests := []struct {
+		format Format
+		doc    string
+		want   string
+	}{
+		{
+			FORMAT_JSON,
+			"{\n  // The active profile\n  \"profile\": \"work\",\n  \"profiles\": {\n    \"work\": {\"model\": \"small\"}, // cheap\n  },\n}\n",
+			"{\n  // The active profile\n  \"profile\": \"home\",\n  \"profiles\": {\n    \"work\": {\"model\": \"large\", \"retries\": 3}, // cheap\n  },\n  \"empty\": null,\n}\n",
+		},
+		{
+			FORMAT_YAML,
+			"# The active profile\nprofile: \"work\"\nprofiles:\n  work:\n    model: small # cheap\n",
+			"# The active profile\nprofile: \"home\"\nprofiles:\n  work:\n    model: large # cheap\n    retries: 3\nempty: null\n",
+		},
+	}
+
+	for _, tt := range tests {
+		f, err := Parse([]byte(tt.doc), tt.format)
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", tt.format, err)
+		}
+
+		var config testConfig
+		if err := f.Decode(&config); err != nil {
+			t.Fatalf("%s: Decode failed: %v", tt.format, err)
+		}
+
+		config.Profile = "home"
+		config.Profiles["work"]["model"] = "large"
+		config.Profiles["work"]["retries"] = 3
+
+		if err := f.Update(config); err != nil {
+			t.Fatalf("%s: Update failed: %v", tt.format, err)
+		}
+
+		if got := string(f.Bytes()); got != tt.want {
+			t.Fatalf("%s: expected\n%s\ngot\n%s", tt.format, tt.want, got)
+		}
+	}
+}
+
+func TestUpdateTOML(t *testing.T) {
+	f, err := Parse([]byte("# comment\nprofile = \"work\"\n"), FORMAT_TOML)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	config := testConfig{
+		Profile:  "home",
+		Profiles: map[string]map[string]any{"home": {"retries": 3, "rate": 0.5}},
+	}
+
+	if err := f.Update(config); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	var got testConfig
+	if err := f.Decode(&got); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if got.Profile != "home" || got.Profiles["home"]["retries"] != 3.0 || got.Profiles["home"]["rate"] != 0.5 {
+		t.Fatalf("unexpected config after update: %+v\n%s", got, f.Bytes())
+	}
+
+	if strings.Contains(string(f.Bytes()), "empty") {
+		t.Fatalf("expected null values to be left out:\n%s", f.Bytes())
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	for _, format := range []Format{FORMAT_JSON, FORMAT_YAML, FORMAT_TOML} {
+		f := New(format)
+
+		if err := f.Update(map[string]any{"profile": "default"}); err != nil {
+			t.Fatalf("%s: Update failed: %v", format, err)
+		}
+
+		path := filepath.Join(t.TempDir(), "config."+string(format))
+		if err := os.WriteFile(path, f.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		read, err := Read(path)
+		if err != nil {
+			t.Fatalf("%s: Read failed: %v", format, err)
+		}
+
+		if got := string(read.JSON()); !strings.Contains(got, `"profile"`) {
+			t.Fatalf("%s: unexpected document %s", format, got)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	tests := []struct {
+		format Format
+		doc    string
+		path   []string
+		want   jsonc.Position
+	}{
+		{FORMAT_JSON, "{\n  \"a\": {\"b\": 1}\n}", []string{"a", "b"}, jsonc.Position{Line: 2, Column: 9}},
+		{FORMAT_YAML, "a:\n  b: 1\n  c: [x, y]\n", []string{"a", "b"}, jsonc.Position{Line: 2, Column: 3}},
+		{FORMAT_YAML, "a:\n  b: 1\n  c: [x, y]\n", []string{"a", "c", "1"}, jsonc.Position{Line: 3, Column: 10}},
+		{FORMAT_YAML, "a:\n  b: 1\n", []string{"a", "missing"}, jsonc.Position{Line: 1, Column: 1}},
+		{FORMAT_TOML, "[a]\nb = 1\n", []string{"a", "b"}, jsonc.Position{}},
+	}
+
+	for _, tt := range tests {
+		f, err := Parse([]byte(tt.doc), tt.format)
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", tt.format, err)
+		}
+
+		if got := f.Position(tt.path); got != tt.want {
+			t.Fatalf("%s: position of %q: expected %s, got %s", tt.format, tt.path, tt.want, got)
+		}
+	}
+}
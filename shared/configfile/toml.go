@@ -0,0 +1,51 @@
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mcnull/qai/shared/jsonc"
+)
+
+func (f *File) parseTOML() error {
+	v := map[string]any{}
+
+	if err := toml.Unmarshal(f.data, &v); err != nil {
+		var pErr toml.ParseError
+		if errors.As(err, &pErr) {
+			return &jsonc.SyntaxError{
+				Position: jsonc.Position{Line: pErr.Position.Line, Column: pErr.Position.Col},
+				Message:  pErr.Message,
+			}
+		}
+		return err
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error converting TOML to JSON: %w", err)
+	}
+
+	f.json = data
+
+	return nil
+}
+
+// marshalTOML encodes v as TOML. TOML has no null, keys with a null value are left out.
+func marshalTOML(v any) ([]byte, error) {
+	plain, err := plainValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	if err := toml.NewEncoder(&buf).Encode(plain); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
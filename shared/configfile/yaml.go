@@ -0,0 +1,227 @@
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"github.com/mcnull/qai/shared/jsonc"
+	"gopkg.in/yaml.v3"
+)
+
+// YAML_INDENT is the indentation of YAML files written from scratch.
+const YAML_INDENT = 2
+
+// yamlErrorPattern matches the line number in the errors of the YAML parser.
+var yamlErrorPattern = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+func (f *File) parseYAML() error {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(f.data, &doc); err != nil {
+		return yamlSyntaxError(err)
+	}
+
+	var v any
+	if len(doc.Content) > 0 {
+		if err := doc.Decode(&v); err != nil {
+			return yamlSyntaxError(err)
+		}
+	}
+
+	if v == nil {
+		// An empty file or only comments
+		v = map[string]any{}
+	}
+
+	data, err := json.Marshal(jsonValue(v))
+	if err != nil {
+		return fmt.Errorf("error converting YAML to JSON: %w", err)
+	}
+
+	f.node = &doc
+	f.json = data
+
+	return nil
+}
+
+func yamlSyntaxError(err error) error {
+	if m := yamlErrorPattern.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return &jsonc.SyntaxError{Position: jsonc.Position{Line: line}, Message: m[2]}
+	}
+
+	return err
+}
+
+// jsonValue converts decoded YAML to values encoding/json can marshal. Keys that
+// are not strings, such as numbers, are converted to strings.
+func jsonValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, child := range value {
+			value[k] = jsonValue(child)
+		}
+	case map[any]any:
+		m := make(map[string]any, len(value))
+		for k, child := range value {
+			m[fmt.Sprint(k)] = jsonValue(child)
+		}
+		return m
+	case []any:
+		for i, child := range value {
+			value[i] = jsonValue(child)
+		}
+	}
+
+	return v
+}
+
+func (f *File) updateYAML(v any) ([]byte, error) {
+	plain, err := plainValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated yaml.Node
+	if err := updated.Encode(plain); err != nil {
+		return nil, err
+	}
+
+	doc := f.node
+	if doc == nil || len(doc.Content) == 0 {
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&updated}}
+		if f.node != nil {
+			// Keep the comments of a file without content
+			doc.HeadComment = f.node.HeadComment
+			doc.FootComment = f.node.FootComment
+		}
+	} else {
+		mergeYAML(doc.Content[0], &updated)
+	}
+
+	var buf bytes.Buffer
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(YAML_INDENT)
+
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// mergeYAML changes node to hold the value of updated, keeping the comments, styles and
+// key order of the values that are still there.
+func mergeYAML(node, updated *yaml.Node) {
+	switch {
+	case node.Kind == yaml.MappingNode && updated.Kind == yaml.MappingNode:
+		content := make([]*yaml.Node, 0, len(updated.Content))
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if value := mappingValue(updated, node.Content[i].Value); value != nil {
+				mergeYAML(node.Content[i+1], value)
+				content = append(content, node.Content[i], node.Content[i+1])
+			}
+		}
+
+		// New keys are added at the end
+		for i := 0; i+1 < len(updated.Content); i += 2 {
+			if mappingValue(node, updated.Content[i].Value) == nil {
+				content = append(content, updated.Content[i], updated.Content[i+1])
+			}
+		}
+
+		node.Content = content
+		return
+	case node.Kind == yaml.SequenceNode && updated.Kind == yaml.SequenceNode && len(node.Content) == len(updated.Content):
+		for i := range node.Content {
+			mergeYAML(node.Content[i], updated.Content[i])
+		}
+		return
+	}
+
+	if sameYAMLValue(node, updated) {
+		return
+	}
+
+	replaced := *updated
+	replaced.HeadComment = node.HeadComment
+	replaced.LineComment = node.LineComment
+	replaced.FootComment = node.FootComment
+
+	if node.Kind == yaml.ScalarNode && updated.Kind == yaml.ScalarNode && updated.Tag == "!!str" &&
+		node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		// Keep quoted strings quoted
+		replaced.Style = node.Style
+	}
+
+	*node = replaced
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func sameYAMLValue(a, b *yaml.Node) bool {
+	var va, vb any
+
+	if a.Decode(&va) != nil || b.Decode(&vb) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
+
+// yamlPosition returns the position of the key holding the value at path in doc, or
+// of the value for sequence items.
+func yamlPosition(doc *yaml.Node, path []string) jsonc.Position {
+	if len(doc.Content) == 0 {
+		return jsonc.Position{Line: 1, Column: 1}
+	}
+
+	node := doc.Content[0]
+	pos := jsonc.Position{Line: node.Line, Column: node.Column}
+
+	for _, name := range path {
+		var next, at *yaml.Node
+
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == name {
+					at, next = node.Content[i], node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < len(node.Content) {
+				next = node.Content[i]
+				at = next
+			}
+		}
+
+		if next == nil {
+			// Report the closest parent
+			break
+		}
+
+		node = next
+		pos = jsonc.Position{Line: at.Line, Column: at.Column}
+	}
+
+	return pos
+}
@@ -0,0 +1,153 @@
+package desktop
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ErrNoClipboard is returned when no clipboard utility is available and the OSC52 fallback cannot be used.
+var ErrNoClipboard = errors.New("no clipboard utility found")
+
+// ErrNoBrowser is returned when no command could be found to open a URL.
+var ErrNoBrowser = errors.New("no browser found")
+
+// Injected for testing
+var (
+	lookPath              = exec.LookPath
+	execCommand           = exec.Command
+	getenv                = os.Getenv
+	goos                  = runtime.GOOS
+	osc52Output io.Writer = os.Stderr
+)
+
+// OpenURL opens the url in the user's default browser.
+// The $BROWSER environment variable takes precedence over the platform default.
+func OpenURL(url string) error {
+
+	for _, args := range browserCommands(getenv("BROWSER"), url) {
+		if _, err := lookPath(args[0]); err != nil {
+			continue
+		}
+
+		cmd := execCommand(args[0], args[1:]...)
+		if err := cmd.Start(); err != nil {
+			continue
+		}
+
+		// Don't wait for the browser to exit, just reap the process when it does.
+		go cmd.Wait()
+
+		return nil
+	}
+
+	return ErrNoBrowser
+}
+
+// WriteClipboard copies text to the system clipboard.
+// When no clipboard utility is available it falls back to the OSC52 terminal escape sequence.
+func WriteClipboard(text string) error {
+
+	for _, args := range clipboardCommands() {
+		if _, err := lookPath(args[0]); err != nil {
+			continue
+		}
+
+		cmd := execCommand(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+
+		if err := cmd.Run(); err != nil {
+			continue
+		}
+
+		return nil
+	}
+
+	return writeOSC52(text)
+}
+
+// browserCommands returns the candidate commands to open url, in order of preference.
+// browserEnv follows the $BROWSER convention: a list of commands separated by the path
+// list separator, where "%s" is replaced by the url or the url is appended as the last argument.
+func browserCommands(browserEnv string, url string) [][]string {
+	var cmds [][]string
+
+	for _, entry := range strings.Split(browserEnv, string(os.PathListSeparator)) {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+
+		replaced := false
+		for i, f := range fields {
+			if strings.Contains(f, "%s") {
+				fields[i] = strings.ReplaceAll(f, "%s", url)
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			fields = append(fields, url)
+		}
+
+		cmds = append(cmds, fields)
+	}
+
+	switch goos {
+	case "darwin":
+		cmds = append(cmds, []string{"open", url})
+	case "windows":
+		cmds = append(cmds, []string{"rundll32", "url.dll,FileProtocolHandler", url})
+		cmds = append(cmds, []string{"cmd", "/c", "start", "", url})
+	default:
+		cmds = append(cmds, []string{"xdg-open", url})
+	}
+
+	return cmds
+}
+
+// clipboardCommands returns the candidate clipboard utilities for the current platform, in order of preference.
+func clipboardCommands() [][]string {
+	switch goos {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip"}}
+	}
+
+	var cmds [][]string
+
+	if getenv("WAYLAND_DISPLAY") != "" {
+		cmds = append(cmds, []string{"wl-copy"})
+	}
+
+	if getenv("DISPLAY") != "" {
+		cmds = append(cmds, []string{"xclip", "-selection", "clipboard"})
+		cmds = append(cmds, []string{"xsel", "--clipboard", "--input"})
+	}
+
+	return cmds
+}
+
+// writeOSC52 asks the terminal emulator to set the clipboard. This also works over SSH
+// as long as the terminal supports it.
+func writeOSC52(text string) error {
+	if f, ok := osc52Output.(*os.File); ok && !term.IsTerminal(int(f.Fd())) {
+		return ErrNoClipboard
+	}
+
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+
+	if _, err := io.WriteString(osc52Output, seq); err != nil {
+		return fmt.Errorf("error writing OSC52 sequence: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package desktop
+
+import (
+	"bytes"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func withGOOS(t *testing.T, os string) {
+	t.Helper()
+	orig := goos
+	goos = os
+	t.Cleanup(func() { goos = orig })
+}
+
+func TestBrowserCommandsDefault(t *testing.T) {
+	withGOOS(t, "linux")
+
+	cmds := browserCommands("", "https://example.com")
+	want := [][]string{{"xdg-open", "https://example.com"}}
+
+	if !reflect.DeepEqual(cmds, want) {
+		t.Fatalf("unexpected commands: %v", cmds)
+	}
+}
+
+func TestBrowserCommandsFromEnv(t *testing.T) {
+	withGOOS(t, "darwin")
+
+	cmds := browserCommands("firefox --new-tab %s:lynx", "https://example.com")
+	want := [][]string{
+		{"firefox", "--new-tab", "https://example.com"},
+		{"lynx", "https://example.com"},
+		{"open", "https://example.com"},
+	}
+
+	if !reflect.DeepEqual(cmds, want) {
+		t.Fatalf("unexpected commands: %v", cmds)
+	}
+}
+
+func TestWriteClipboardFallsBackToOSC52(t *testing.T) {
+	withGOOS(t, "linux")
+
+	origLookPath, origOutput := lookPath, osc52Output
+	t.Cleanup(func() { lookPath, osc52Output = origLookPath, origOutput })
+
+	lookPath = func(file string) (string, error) { return "", exec.ErrNotFound }
+
+	var buf bytes.Buffer
+	osc52Output = &buf
+
+	if err := WriteClipboard("hello"); err != nil {
+		t.Fatalf("WriteClipboard failed: %v", err)
+	}
+
+	if got, want := buf.String(), "\x1b]52;c;aGVsbG8=\a"; got != want {
+		t.Fatalf("unexpected OSC52 sequence: %q, want %q", got, want)
+	}
+}
@@ -74,7 +74,7 @@ func Parse(args []string, options *ParseOptions) ([]string, error) {
 	return options.FlagSet.Args(), nil
 }
 
-// Returns a new array of arguments merged with the provided args.
+// Returns a new array of arguments merged with the provided args, with the merged keys in front.
 // Only key starting with - or -- are considered to be merged.
 // Keys already present in the args will be skipped.
 func merge(args []string, argsMap FlagEnvMap) ([]string, error) {
@@ -92,16 +92,20 @@ func merge(args []string, argsMap FlagEnvMap) ([]string, error) {
 		return nil, fmt.Errorf("error normalizing args: %w", err)
 	}
 
+	var envArgs []string
+
 	for k, v := range argsMap {
 		// Check if the key is already present in the args
 		found := slices.Contains(nn, k)
 		if !found {
-			// If not, append the key and value to the args
-			args = append(args, fmt.Sprintf("--%s=%s", k, v))
+			// If not, add the key and value to the args
+			envArgs = append(envArgs, fmt.Sprintf("--%s=%s", k, v))
 		}
 	}
 
-	return args, nil
+	// Flag parsing stops at the first positional argument, so the environment
+	// flags go in front of the command line arguments
+	return append(envArgs, args...), nil
 }
 
 // Returns a new array containing all the arguments starting with - or --.
@@ -0,0 +1,254 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	DEFAULT_MAX_RETRIES     = 2
+	DEFAULT_BASE_DELAY      = 500 * time.Millisecond
+	DEFAULT_MAX_DELAY       = 10 * time.Second
+	DEFAULT_MAX_RETRY_AFTER = 60 * time.Second
+)
+
+// Client wraps an http.Client and retries requests that fail with a transient error.
+//
+// Only the request itself is retried: once Do has returned a response the caller owns
+// the body, so a stream that breaks after output has begun is never replayed.
+type Client struct {
+	client        *http.Client
+	maxRetries    int
+	baseDelay     time.Duration
+	maxDelay      time.Duration
+	maxRetryAfter time.Duration
+}
+
+// New creates a client that retries a failed request up to maxRetries times.
+func New(maxRetries int) *Client {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	return &Client{
+		client:        &http.Client{},
+		maxRetries:    maxRetries,
+		baseDelay:     DEFAULT_BASE_DELAY,
+		maxDelay:      DEFAULT_MAX_DELAY,
+		maxRetryAfter: DEFAULT_MAX_RETRY_AFTER,
+	}
+}
+
+// Default returns a client with the default number of retries.
+func Default() *Client {
+	return New(DEFAULT_MAX_RETRIES)
+}
+
+// WithTransport sets the transport used to send requests.
+func (c *Client) WithTransport(transport http.RoundTripper) *Client {
+	c.client.Transport = transport
+	return c
+}
+
+// WithBackoff sets the base and maximum delay between retries.
+func (c *Client) WithBackoff(base, max time.Duration) *Client {
+	c.baseDelay = base
+	c.maxDelay = max
+	return c
+}
+
+// WithMaxRetryAfter sets the longest Retry-After the client is willing to wait for.
+// Responses asking for a longer wait are returned to the caller as-is.
+func (c *Client) WithMaxRetryAfter(max time.Duration) *Client {
+	c.maxRetryAfter = max
+	return c
+}
+
+// Transport returns the transport used to send requests.
+func (c *Client) Transport() http.RoundTripper {
+	if c.client.Transport == nil {
+		return http.DefaultTransport
+	}
+	return c.client.Transport
+}
+
+// Do sends the request, retrying on connection errors and timeouts before any response,
+// see isRetryableError, and on 429, 502, 503 and 504 responses.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+
+	for attempt := 0; ; attempt++ {
+
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		var progress requestProgress
+
+		resp, err := c.client.Do(progress.trace(req))
+
+		if attempt >= c.maxRetries || !c.canRetry(req, resp, err, &progress) {
+			return resp, err
+		}
+
+		delay, ok := c.delay(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) canRetry(req *http.Request, resp *http.Response, err error, progress *requestProgress) bool {
+
+	if req.Context().Err() != nil {
+		return false
+	}
+
+	// The body can't be sent again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		return isRetryableError(err, progress.wrote.Load(), progress.responded.Load())
+	}
+
+	return IsRetryableStatus(resp.StatusCode)
+}
+
+// requestProgress records how far an attempt got before it failed.
+type requestProgress struct {
+	wrote     atomic.Bool
+	responded atomic.Bool
+}
+
+// trace returns req with a context that updates the progress.
+func (p *requestProgress) trace(req *http.Request) *http.Request {
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteRequest:         func(httptrace.WroteRequestInfo) { p.wrote.Store(true) },
+		GotFirstResponseByte: func() { p.responded.Store(true) },
+	}))
+}
+
+// isRetryableError reports whether a request that failed with err can be sent again: when
+// the connection could not be made, or when it was reset or timed out before the request
+// was written. A request that reached the server may have been handled, so it is never
+// sent again.
+func isRetryableError(err error, wrote, responded bool) bool {
+	if wrote || responded {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// delay returns how long to wait before the next attempt, and false if the
+// server asked for a longer wait than the client is willing to do.
+func (c *Client) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+
+	if resp != nil {
+		if d, ok := RetryAfter(resp); ok {
+			return d, d <= c.maxRetryAfter
+		}
+	}
+
+	return Backoff(attempt, c.baseDelay, c.maxDelay), true
+}
+
+// IsRetryableStatus reports whether a response with the given status code is worth retrying.
+func IsRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Backoff returns the exponential delay for the given attempt with jitter applied.
+// The result lies between half and the full exponential delay, capped at max.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	d := base << attempt
+
+	if d > max || d <= 0 {
+		d = max
+	}
+
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+
+	return half + rand.N(half+1)
+}
+
+// RetryAfter parses the Retry-After header of the response, which holds either
+// a number of seconds or an HTTP date.
+func RetryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			secs = 0
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,315 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newServer returns a server that answers with the given status codes in order,
+// followed by 200 OK once the list is exhausted.
+func newServer(t *testing.T, header http.Header, codes ...int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1)) - 1
+
+		body, _ := io.ReadAll(r.Body)
+
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+
+		if n < len(codes) {
+			w.WriteHeader(codes[n])
+			return
+		}
+
+		w.Write(body)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, &calls
+}
+
+func newTestClient(retries int) *Client {
+	return New(retries).WithBackoff(time.Millisecond, 5*time.Millisecond)
+}
+
+func TestDoRetriesTransientStatus(t *testing.T) {
+	srv, calls := newServer(t, nil, 503, 429, 502)
+
+	req, _ := http.NewRequest("POST", srv.URL, bytes.NewBufferString("payload"))
+
+	resp, err := newTestClient(3).Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if string(body) != "payload" {
+		t.Fatalf("request body was not replayed, got %q", body)
+	}
+	if n := atomic.LoadInt32(calls); n != 4 {
+		t.Fatalf("expected 4 requests, got %d", n)
+	}
+}
+
+func TestDoGivesUpAfterMaxRetries(t *testing.T) {
+	srv, calls := newServer(t, nil, 503, 503, 503)
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+
+	resp, err := newTestClient(1).Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 503 {
+		t.Fatalf("expected 503, got %d", resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Fatalf("expected 2 requests, got %d", n)
+	}
+}
+
+func TestDoDoesNotRetryClientErrors(t *testing.T) {
+	srv, calls := newServer(t, nil, 400)
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+
+	resp, err := newTestClient(3).Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Fatalf("expected 1 request, got %d", n)
+	}
+}
+
+func TestDoRetriesConnectionErrors(t *testing.T) {
+	srv, _ := newServer(t, nil)
+	url := srv.URL
+	srv.Close()
+
+	var attempts int32
+	client := newTestClient(2).WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&attempts, 1)
+		return http.DefaultTransport.RoundTrip(r)
+	}))
+
+	req, _ := http.NewRequest("GET", url, nil)
+
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("expected connection error")
+	}
+	if n := atomic.LoadInt32(&attempts); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+}
+
+func TestDoHonorsRetryAfter(t *testing.T) {
+	srv, calls := newServer(t, http.Header{"Retry-After": {"1"}}, 429)
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+
+	start := time.Now()
+	resp, err := newTestClient(1).Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("Retry-After was not honored, elapsed %s", elapsed)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Fatalf("expected 2 requests, got %d", n)
+	}
+}
+
+func TestDoDoesNotWaitForLongRetryAfter(t *testing.T) {
+	srv, calls := newServer(t, http.Header{"Retry-After": {"3600"}}, 429)
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+
+	resp, err := newTestClient(3).Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 429 {
+		t.Fatalf("expected 429, got %d", resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Fatalf("expected 1 request, got %d", n)
+	}
+}
+
+func TestDoStopsWhenContextIsCancelled(t *testing.T) {
+	srv, _ := newServer(t, nil, 503, 503, 503)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+
+	client := New(3).WithBackoff(time.Second, time.Second)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := client.Do(req)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 10; attempt++ {
+		d := Backoff(attempt, 100*time.Millisecond, time.Second)
+
+		max := min((100*time.Millisecond)<<attempt, time.Second)
+		if d < max/2 || d > max {
+			t.Fatalf("attempt %d: delay %s out of range [%s, %s]", attempt, d, max/2, max)
+		}
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// timeoutError is a network error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestDoRetriesOnlyTransientErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts int32
+	}{
+		{"timeout", timeoutError{}, 3},
+		{"reset before the request was written", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, 3},
+		{"other error", errors.New("malformed response"), 1},
+	}
+
+	for _, tt := range tests {
+		var attempts int32
+		client := newTestClient(2).WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&attempts, 1)
+			return nil, tt.err
+		}))
+
+		req, _ := http.NewRequest("GET", "http://localhost/", nil)
+
+		if _, err := client.Do(req); err == nil {
+			t.Fatalf("%s: expected an error", tt.name)
+		}
+		if n := atomic.LoadInt32(&attempts); n != tt.attempts {
+			t.Fatalf("%s: expected %d attempts, got %d", tt.name, tt.attempts, n)
+		}
+	}
+}
+
+// A connection that breaks once the server has read the request, or after the response
+// has started, is not retried: the request may have been handled.
+func TestDoDoesNotRetryAfterRequestWasWritten(t *testing.T) {
+	for _, response := range []string{"", "HTTP/1.1 200 OK\r\nContent-Le"} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ln.Close() })
+
+		var accepted int32
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+
+				atomic.AddInt32(&accepted, 1)
+
+				http.ReadRequest(bufio.NewReader(conn))
+				io.WriteString(conn, response)
+
+				// Reset the connection instead of closing it
+				conn.(*net.TCPConn).SetLinger(0)
+				conn.Close()
+			}
+		}()
+
+		req, _ := http.NewRequest("POST", "http://"+ln.Addr().String(), bytes.NewReader([]byte(`{}`)))
+
+		if _, err := newTestClient(2).Do(req); err == nil {
+			t.Fatalf("%q: expected an error", response)
+		}
+		if n := atomic.LoadInt32(&accepted); n != 1 {
+			t.Fatalf("%q: expected 1 connection, got %d", response, n)
+		}
+	}
+}
+
+// A request that reached the server and then timed out waiting for the response is not
+// sent again, the server may still answer it.
+func TestDoDoesNotRetryTimeoutAfterRequestWasWritten(t *testing.T) {
+	var calls int32
+	stall := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		io.ReadAll(r.Body)
+		<-stall
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(stall) })
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 50 * time.Millisecond
+
+	client := newTestClient(2).WithTransport(transport)
+
+	req, _ := http.NewRequest("POST", srv.URL, bytes.NewReader([]byte(`{"prompt":"hi"}`)))
+
+	_, err := client.Do(req)
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected 1 attempt, got %d", n)
+	}
+}
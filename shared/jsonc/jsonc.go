@@ -0,0 +1,92 @@
+// Package jsonc reads and edits JSON documents with comments and trailing commas, as
+// written by hand in config files.
+package jsonc
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Standardize returns a copy of data with comments and trailing commas replaced by spaces,
+// so it can be parsed as plain JSON. Offsets and line numbers are unchanged.
+func Standardize(data []byte) []byte {
+	out := bytes.Clone(data)
+
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if out[i] != '\n' && out[i] != '\r' {
+				out[i] = ' '
+			}
+		}
+	}
+
+	// Comments
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '"':
+			i = stringEnd(out, i)
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			end := bytes.IndexByte(out[i:], '\n')
+			if end < 0 {
+				end = len(out) - i
+			}
+			blank(i, i+end)
+			i += end
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				blank(i, len(out))
+				return out
+			}
+			blank(i, i+2+end+2)
+			i += 2 + end + 1
+		}
+	}
+
+	// Trailing commas, only after a value so a stray comma is still a syntax error
+	var prev byte
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+
+		switch c {
+		case '"':
+			i = stringEnd(out, i)
+		case ',':
+			next := i + 1
+			for next < len(out) && isSpace(out[next]) {
+				next++
+			}
+			if next < len(out) && (out[next] == '}' || out[next] == ']') && prev != ',' && prev != '{' && prev != '[' {
+				out[i] = ' '
+			}
+		}
+
+		if !isSpace(c) {
+			prev = c
+		}
+	}
+
+	return out
+}
+
+// Unmarshal parses the JSON document with comments in data into v.
+func Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(Standardize(data), v)
+}
+
+// stringEnd returns the offset of the closing quote of the string starting at start.
+func stringEnd(data []byte, start int) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return len(data)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
@@ -0,0 +1,49 @@
+package jsonc
+
+import (
+	"testing"
+)
+
+func TestStandardize(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{`{"a": 1}`, `{"a": 1}`},
+		{"{\n  // note\n  \"a\": 1\n}", "{\n         \n  \"a\": 1\n}"},
+		{`{"a": /* x */ 1}`, `{"a":         1}`},
+		{`{"a": "// not a comment"}`, `{"a": "// not a comment"}`},
+		{`{"a": "\"/*"}`, `{"a": "\"/*"}`},
+		{"[1, 2,\n]", "[1, 2 \n]"},
+		{`{"a": [1,],}`, `{"a": [1 ] }`},
+		{`[1,,]`, `[1,,]`},
+	}
+
+	for _, tt := range tests {
+		got := string(Standardize([]byte(tt.doc)))
+		if got != tt.want {
+			t.Fatalf("Standardize(%q): expected %q, got %q", tt.doc, tt.want, got)
+		}
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	doc := `{
+  // The model to use
+  "model": "small", /* for now */
+  "tags": ["a", "b",],
+}`
+
+	var v struct {
+		Model string   `json:"model"`
+		Tags  []string `json:"tags"`
+	}
+
+	if err := Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if v.Model != "small" || len(v.Tags) != 2 {
+		t.Fatalf("unexpected result: %+v", v)
+	}
+}
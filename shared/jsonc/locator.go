@@ -0,0 +1,209 @@
+package jsonc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Position is a 1-based line and column in a document. The column counts characters,
+// it is zero when only the line is known.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Column == 0 {
+		return strconv.Itoa(p.Line)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Locator finds the position of values and object keys in a JSON document.
+// Paths are the property names and array indexes leading to a value.
+type Locator struct {
+	source  []byte
+	data    []byte
+	values  map[string]int
+	ends    map[string]int
+	keys    map[string]int
+	members map[string][]string
+}
+
+// NewLocator indexes the JSON document in data, which may contain comments and trailing
+// commas. A syntax error is returned as a *SyntaxError.
+func NewLocator(data []byte) (*Locator, error) {
+	l := &Locator{
+		source:  data,
+		data:    Standardize(data),
+		values:  map[string]int{},
+		ends:    map[string]int{},
+		keys:    map[string]int{},
+		members: map[string][]string{},
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(l.data))
+	dec.UseNumber()
+
+	if err := l.index(dec, nil); err != nil {
+		return nil, l.syntaxError(dec, err)
+	}
+
+	end := l.skip(int(dec.InputOffset()))
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, &SyntaxError{
+			Position: l.offsetPosition(end),
+			Message:  "unexpected data after the top-level value",
+		}
+	}
+
+	return l, nil
+}
+
+// JSON returns the indexed document as plain JSON, with the same offsets as the original.
+func (l *Locator) JSON() []byte {
+	return l.data
+}
+
+// Value returns the position of the value at path, or of its closest indexed parent.
+func (l *Locator) Value(path []string) Position {
+	for i := len(path); i >= 0; i-- {
+		if off, ok := l.values[pathKey(path[:i])]; ok {
+			return l.offsetPosition(off)
+		}
+	}
+	return Position{Line: 1, Column: 1}
+}
+
+// Key returns the position of the object key that holds the value at path.
+// The position of the value is returned for array items and the document root.
+func (l *Locator) Key(path []string) Position {
+	if off, ok := l.keys[pathKey(path)]; ok {
+		return l.offsetPosition(off)
+	}
+	return l.Value(path)
+}
+
+// SyntaxError is a JSON syntax error with the position where it was detected.
+type SyntaxError struct {
+	Position Position
+	Message  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Position, e.Message)
+}
+
+func (l *Locator) index(dec *json.Decoder, path []string) error {
+	key := pathKey(path)
+	l.values[key] = l.skip(int(dec.InputOffset()))
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		l.ends[key] = int(dec.InputOffset())
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		l.members[key] = []string{}
+
+		for dec.More() {
+			keyOffset := l.skip(int(dec.InputOffset()))
+
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+
+			name, _ := tok.(string)
+			child := append(path[:len(path):len(path)], name)
+			l.keys[pathKey(child)] = keyOffset
+			l.members[key] = append(l.members[key], name)
+
+			if err := l.index(dec, child); err != nil {
+				return err
+			}
+		}
+	case '[':
+		for i := 0; dec.More(); i++ {
+			child := append(path[:len(path):len(path)], strconv.Itoa(i))
+			if err := l.index(dec, child); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Closing delimiter
+	if _, err = dec.Token(); err != nil {
+		return err
+	}
+
+	l.ends[key] = int(dec.InputOffset())
+
+	return nil
+}
+
+// skip returns the offset of the next token after off, skipping whitespace and separators.
+func (l *Locator) skip(off int) int {
+	for off < len(l.data) {
+		switch l.data[off] {
+		case ' ', '\t', '\r', '\n', ':', ',':
+			off++
+		default:
+			return off
+		}
+	}
+	return off
+}
+
+func (l *Locator) syntaxError(dec *json.Decoder, err error) error {
+	off := int(dec.InputOffset())
+	message := err.Error()
+
+	var sErr *json.SyntaxError
+	if errors.As(err, &sErr) {
+		off = int(sErr.Offset)
+		// The offset points just past the offending character, or at the end of a truncated document
+		if off > 0 && !strings.HasPrefix(sErr.Error(), "unexpected end") {
+			off--
+		}
+	} else if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		off = len(l.data)
+		message = "unexpected end of JSON input"
+	}
+
+	return &SyntaxError{Position: l.offsetPosition(off), Message: message}
+}
+
+func (l *Locator) offsetPosition(off int) Position {
+	if off > len(l.source) {
+		off = len(l.source)
+	}
+
+	// Columns are counted in the source, comments may contain multibyte characters
+	before := l.source[:off]
+	line := bytes.Count(before, []byte("\n")) + 1
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+
+	return Position{
+		Line:   line,
+		Column: utf8.RuneCount(before[lineStart:]) + 1,
+	}
+}
+
+func pathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
@@ -0,0 +1,64 @@
+package jsonc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLocator(t *testing.T) {
+	doc := "{\n  \"a\": {\"b\": [1, \"ü\", {\"c\": true}]},\n  \"d\":\n    null\n}"
+
+	l, err := NewLocator([]byte(doc))
+	if err != nil {
+		t.Fatalf("NewLocator failed: %v", err)
+	}
+
+	tests := []struct {
+		path []string
+		key  bool
+		want Position
+	}{
+		{nil, false, Position{1, 1}},
+		{[]string{"a"}, true, Position{2, 3}},
+		{[]string{"a"}, false, Position{2, 8}},
+		{[]string{"a", "b", "1"}, false, Position{2, 18}},
+		{[]string{"a", "b", "2", "c"}, false, Position{2, 29}},
+		{[]string{"d"}, false, Position{4, 5}},
+		{[]string{"d", "missing"}, false, Position{4, 5}},
+	}
+
+	for _, tt := range tests {
+		got := l.Value(tt.path)
+		if tt.key {
+			got = l.Key(tt.path)
+		}
+
+		if got != tt.want {
+			t.Fatalf("position of %q (key %v): expected %s, got %s", tt.path, tt.key, tt.want, got)
+		}
+	}
+}
+
+func TestLocatorSyntaxError(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want Position
+	}{
+		{"{\n  \"a\": 1,\n  ,\n}", Position{3, 3}},
+		{"{\n  \"a\": [1, 2", Position{2, 13}},
+		{"{} {}", Position{1, 4}},
+	}
+
+	for _, tt := range tests {
+		_, err := NewLocator([]byte(tt.doc))
+
+		var sErr *SyntaxError
+		if !errors.As(err, &sErr) {
+			t.Fatalf("expected a syntax error for %q, got %v", tt.doc, err)
+		}
+
+		if sErr.Position != tt.want {
+			t.Fatalf("syntax error in %q: expected position %s, got %s (%s)", tt.doc, tt.want, sErr.Position, sErr.Message)
+		}
+	}
+}
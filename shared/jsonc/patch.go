@@ -0,0 +1,393 @@
+package jsonc
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DEFAULT_INDENT is used for new members when the indentation of a document can't be detected.
+const DEFAULT_INDENT = "  "
+
+// Patch returns data changed to encode v. Comments, formatting and the order of the
+// members that did not change are kept; new members are added at the end of their object.
+func Patch(data []byte, v any) ([]byte, error) {
+	l, err := NewLocator(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var old any
+	if err := decode(l.data, &old); err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated any
+	if err := decode(encoded, &updated); err != nil {
+		return nil, err
+	}
+
+	p := &patcher{l: l}
+	p.indent = p.detectIndent()
+	p.diff(nil, old, updated)
+
+	return p.apply(), nil
+}
+
+type edit struct {
+	start, end int
+	text       string
+}
+
+type patcher struct {
+	l      *Locator
+	indent string
+	edits  []edit
+}
+
+func (p *patcher) diff(path []string, old, updated any) {
+	if om, ok := old.(map[string]any); ok {
+		if um, ok := updated.(map[string]any); ok {
+			p.diffObject(path, om, um)
+			return
+		}
+	}
+
+	if oa, ok := old.([]any); ok {
+		if ua, ok := updated.([]any); ok && len(oa) == len(ua) {
+			for i := range oa {
+				p.diff(child(path, strconv.Itoa(i)), oa[i], ua[i])
+			}
+			return
+		}
+	}
+
+	if reflect.DeepEqual(old, updated) {
+		return
+	}
+
+	key := pathKey(path)
+	start := p.l.values[key]
+
+	p.edits = append(p.edits, edit{
+		start: start,
+		end:   p.l.ends[key],
+		text:  p.encode(updated, p.lineIndent(start)),
+	})
+}
+
+func (p *patcher) diffObject(path []string, old, updated map[string]any) {
+	members := p.l.members[pathKey(path)]
+
+	lastKept := -1
+	for i, name := range members {
+		if _, ok := updated[name]; ok {
+			lastKept = i
+		}
+	}
+
+	for i, name := range members {
+		if _, ok := updated[name]; ok {
+			p.diff(child(path, name), old[name], updated[name])
+		} else if i < lastKept {
+			p.removeMember(child(path, name))
+		}
+	}
+
+	var added []string
+	for name := range updated {
+		if _, ok := old[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	slices.Sort(added)
+
+	tailRemoved := lastKept < len(members)-1
+
+	if len(added) == 0 && !tailRemoved {
+		return
+	}
+
+	memberText := func(indent string) []string {
+		var lines []string
+		for _, name := range added {
+			lines = append(lines, indent+strconv.Quote(name)+": "+p.encode(updated[name], indent))
+		}
+		return lines
+	}
+
+	key := pathKey(path)
+	open, close := p.l.values[key], p.l.ends[key]-1
+
+	// A body with comments keeps them, the members are removed one by one
+	commented := !bytes.Equal(p.l.source[open+1:close], p.l.data[open+1:close])
+
+	if lastKept < 0 && (!commented || !bytes.ContainsRune(p.l.data[open:close], '\n')) {
+		// Rewrite the whole body of the object
+		e := edit{start: open + 1, end: close}
+
+		if len(added) > 0 {
+			indent := p.lineIndent(open)
+			e.text = "\n" + strings.Join(memberText(indent+p.indent), ",\n") + "\n" + indent
+		}
+
+		p.edits = append(p.edits, e)
+		return
+	}
+
+	if lastKept < 0 {
+		for _, name := range members {
+			p.removeMember(child(path, name))
+		}
+
+		if len(added) > 0 {
+			// Below the comments, before the line of the closing brace
+			indent := p.lineIndent(open)
+			lineStart := bytes.LastIndexByte(p.l.data[:close], '\n') + 1
+			text := strings.Join(memberText(indent+p.indent), ",\n") + "\n"
+
+			if len(bytes.TrimSpace(p.l.data[lineStart:close])) > 0 {
+				lineStart, text = close, "\n"+text+indent
+			}
+
+			p.edits = append(p.edits, edit{start: lineStart, end: lineStart, text: text})
+		}
+
+		return
+	}
+
+	last := child(path, members[lastKept])
+	indent := p.lineIndent(p.l.keys[pathKey(last)])
+	end := p.l.ends[pathKey(last)]
+
+	if tailRemoved {
+		p.removeTail(path, members[lastKept+1:], end, memberText(indent))
+		return
+	}
+
+	// Add the members after the rest of the line of the last member, which may hold
+	// a comment or a trailing comma
+	eol := end
+	for eol < len(p.l.data) && (p.l.data[eol] == ' ' || p.l.data[eol] == '\t') {
+		eol++
+	}
+
+	if eol < len(p.l.data) && p.l.data[eol] != '\n' && p.l.data[eol] != '\r' {
+		// More members or the closing brace on the same line, a compact object is kept on one line
+		text := ", " + strings.Join(memberText(""), ", ")
+		p.edits = append(p.edits, edit{start: end, end: end, text: text})
+		return
+	}
+
+	trailingComma := false
+	if i := bytes.IndexFunc(p.l.source[end:eol], func(r rune) bool { return r != ' ' && r != '\t' }); i >= 0 {
+		trailingComma = p.l.source[end+i] == ','
+	}
+
+	text := "\n" + strings.Join(memberText(indent), ",\n")
+
+	switch {
+	case trailingComma:
+		text += ","
+	case eol == end:
+		text = "," + text
+	default:
+		p.edits = append(p.edits, edit{start: end, end: end, text: ","})
+	}
+
+	p.edits = append(p.edits, edit{start: eol, end: eol, text: text})
+}
+
+// removeTail removes the members at the end of an object, after the member whose value
+// ends at end, and adds the new member lines in their place. Every member keeps the
+// comments on its line, the comma after the kept member is removed unless the object
+// has a trailing comma or new members follow.
+func (p *patcher) removeTail(path []string, removed []string, end int, lines []string) {
+	data := p.l.data
+	lastEnd := p.l.ends[pathKey(child(path, removed[len(removed)-1]))]
+
+	trailingComma := p.commaAfter(lastEnd)
+
+	firstKey := p.l.keys[pathKey(child(path, removed[0]))]
+
+	if !bytes.ContainsRune(data[end:firstKey], '\n') {
+		// The removed members start on the line of the kept member, as in a compact object
+		e := edit{start: end, end: lastEnd}
+		if len(lines) > 0 {
+			e.text = ", " + strings.Join(trimIndent(lines), ", ")
+		}
+		p.edits = append(p.edits, e)
+		return
+	}
+
+	for _, name := range removed {
+		p.removeMember(child(path, name))
+	}
+
+	comma := end
+	for comma < len(data) && isSpace(data[comma]) {
+		comma++
+	}
+
+	if len(lines) == 0 {
+		if !trailingComma && comma < len(data) && data[comma] == ',' {
+			p.edits = append(p.edits, edit{start: comma, end: comma + 1})
+		}
+		return
+	}
+
+	eol := comma + bytes.IndexByte(data[comma:], '\n')
+
+	text := "\n" + strings.Join(lines, ",\n")
+	if trailingComma {
+		text += ","
+	}
+
+	p.edits = append(p.edits, edit{start: eol, end: eol, text: text})
+}
+
+// commaAfter reports whether the source has a comma after off, before any other token.
+// Trailing commas are blanked in the data, so the source is read, skipping comments.
+func (p *patcher) commaAfter(off int) bool {
+	source := p.l.source
+
+	for off < len(source) {
+		switch {
+		case isSpace(source[off]):
+			off++
+		case bytes.HasPrefix(source[off:], []byte("//")):
+			if i := bytes.IndexByte(source[off:], '\n'); i >= 0 {
+				off += i
+			} else {
+				off = len(source)
+			}
+		case bytes.HasPrefix(source[off:], []byte("/*")):
+			if i := bytes.Index(source[off+2:], []byte("*/")); i >= 0 {
+				off += 2 + i + 2
+			} else {
+				off = len(source)
+			}
+		default:
+			return source[off] == ','
+		}
+	}
+
+	return false
+}
+
+func trimIndent(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimLeft(line, " \t")
+	}
+	return trimmed
+}
+
+// removeMember removes an object member with its comma, the comments on its line and,
+// when it is on a line of its own, the line.
+func (p *patcher) removeMember(path []string) {
+	key := pathKey(path)
+	data := p.l.data
+
+	start := p.l.keys[key]
+	lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+
+	if len(bytes.TrimSpace(data[lineStart:start])) == 0 {
+		start = lineStart
+	}
+
+	end := p.l.ends[key]
+
+	for end < len(data) && (isSpace(data[end]) && data[end] != '\n') {
+		end++
+	}
+
+	if end < len(data) && data[end] == ',' {
+		end++
+	}
+
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t' || data[end] == '\r') {
+		end++
+	}
+
+	if start == lineStart && end < len(data) && data[end] == '\n' {
+		end++
+	}
+
+	p.edits = append(p.edits, edit{start: start, end: end})
+}
+
+func (p *patcher) apply() []byte {
+	slices.SortStableFunc(p.edits, func(a, b edit) int {
+		if a.start != b.start {
+			return b.start - a.start
+		}
+		return b.end - a.end
+	})
+
+	out := bytes.Clone(p.l.source)
+
+	for _, e := range p.edits {
+		out = slices.Concat(out[:e.start], []byte(e.text), out[e.end:])
+	}
+
+	return out
+}
+
+// encode returns v as JSON, indented for a value on a line with the given indentation.
+func (p *patcher) encode(v any, indent string) string {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent(indent, p.indent)
+
+	if err := encoder.Encode(v); err != nil {
+		return "null"
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// lineIndent returns the whitespace at the start of the line holding off.
+func (p *patcher) lineIndent(off int) string {
+	lineStart := bytes.LastIndexByte(p.l.source[:off], '\n') + 1
+
+	end := lineStart
+	for end < off && (p.l.source[end] == ' ' || p.l.source[end] == '\t') {
+		end++
+	}
+
+	return string(p.l.source[lineStart:end])
+}
+
+// detectIndent returns the indentation of the first member of the root object.
+func (p *patcher) detectIndent() string {
+	members := p.l.members[pathKey(nil)]
+
+	if len(members) > 0 {
+		if indent := p.lineIndent(p.l.keys[pathKey([]string{members[0]})]); indent != "" {
+			return indent
+		}
+	}
+
+	return DEFAULT_INDENT
+}
+
+func decode(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func child(path []string, name string) []string {
+	return append(path[:len(path):len(path)], name)
+}
@@ -0,0 +1,146 @@
+package jsonc
+
+import (
+	"testing"
+)
+
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		v    any
+		want string
+	}{
+		{
+			name: "unchanged",
+			doc:  "{\n  // comment\n  \"a\": 1,\n}",
+			v:    map[string]any{"a": 1},
+			want: "{\n  // comment\n  \"a\": 1,\n}",
+		},
+		{
+			name: "change value",
+			doc:  "{\n  // comment\n  \"a\": 1, // one\n  \"b\": \"x\"\n}",
+			v:    map[string]any{"a": 2, "b": "x"},
+			want: "{\n  // comment\n  \"a\": 2, // one\n  \"b\": \"x\"\n}",
+		},
+		{
+			name: "change nested value",
+			doc:  "{\n  \"p\": {\n    \"m\": \"a\" /* model */\n  }\n}",
+			v:    map[string]any{"p": map[string]any{"m": "b"}},
+			want: "{\n  \"p\": {\n    \"m\": \"b\" /* model */\n  }\n}",
+		},
+		{
+			name: "add member",
+			doc:  "{\n  \"a\": 1 // one\n}",
+			v:    map[string]any{"a": 1, "c": 3, "b": 2},
+			want: "{\n  \"a\": 1, // one\n  \"b\": 2,\n  \"c\": 3\n}",
+		},
+		{
+			name: "add member after an object",
+			doc:  "{\n  \"a\": {\n    \"b\": 1\n  }\n}",
+			v:    map[string]any{"a": map[string]any{"b": 1}, "c": 2},
+			want: "{\n  \"a\": {\n    \"b\": 1\n  },\n  \"c\": 2\n}",
+		},
+		{
+			name: "add member after trailing comma",
+			doc:  "{\n\t\"a\": 1,\n}",
+			v:    map[string]any{"a": 1, "b": map[string]any{"c": true}},
+			want: "{\n\t\"a\": 1,\n\t\"b\": {\n\t\t\"c\": true\n\t},\n}",
+		},
+		{
+			name: "add member to compact object",
+			doc:  `{"a": 1}`,
+			v:    map[string]any{"a": 1, "b": 2},
+			want: `{"a": 1, "b": 2}`,
+		},
+		{
+			name: "add member to empty object",
+			doc:  "{\n  \"p\": {}\n}",
+			v:    map[string]any{"p": map[string]any{"a": 1}},
+			want: "{\n  \"p\": {\n    \"a\": 1\n  }\n}",
+		},
+		{
+			name: "remove member",
+			doc:  "{\n  // keep\n  \"a\": 1, // gone\n  \"b\": 2\n}",
+			v:    map[string]any{"b": 2},
+			want: "{\n  // keep\n  \"b\": 2\n}",
+		},
+		{
+			name: "remove last member",
+			doc:  "{\n  \"a\": 1,\n  \"b\": 2\n}",
+			v:    map[string]any{"a": 1},
+			want: "{\n  \"a\": 1\n}",
+		},
+		{
+			name: "remove middle member with line comments",
+			doc:  "{\n  \"a\": 1, // one\n  // about b\n  \"b\": 2, // two\n  \"c\": 3 // three\n}",
+			v:    map[string]any{"a": 1, "c": 3},
+			want: "{\n  \"a\": 1, // one\n  // about b\n  \"c\": 3 // three\n}",
+		},
+		{
+			name: "remove last member with line comments",
+			doc:  "{\n  // members\n  \"a\": 1, // one\n  \"b\": 2 // two\n}",
+			v:    map[string]any{"a": 1},
+			want: "{\n  // members\n  \"a\": 1 // one\n}",
+		},
+		{
+			name: "remove last members after trailing comma",
+			doc:  "{\n  \"a\": 1, // one\n  \"b\": 2, // two\n  \"c\": 3, // three\n}",
+			v:    map[string]any{"a": 1},
+			want: "{\n  \"a\": 1, // one\n}",
+		},
+		{
+			name: "replace last member",
+			doc:  "{\n  \"a\": 1, // one\n  \"b\": 2 // two\n}",
+			v:    map[string]any{"a": 1, "c": 3},
+			want: "{\n  \"a\": 1, // one\n  \"c\": 3\n}",
+		},
+		{
+			name: "remove last member of compact object",
+			doc:  `{"a": 1, "b": 2}`,
+			v:    map[string]any{"a": 1},
+			want: `{"a": 1}`,
+		},
+		{
+			name: "remove all members with comments",
+			doc:  "{\n  // nothing left\n  \"a\": 1 // one\n}",
+			v:    map[string]any{},
+			want: "{\n  // nothing left\n}",
+		},
+		{
+			name: "replace all members with comments",
+			doc:  "{\n  // settings\n  \"a\": 1\n}",
+			v:    map[string]any{"b": 2},
+			want: "{\n  // settings\n  \"b\": 2\n}",
+		},
+		{
+			name: "remove all members",
+			doc:  "{\n  \"a\": 1\n}",
+			v:    map[string]any{},
+			want: "{}",
+		},
+		{
+			name: "replace array",
+			doc:  "{\"a\": [1, 2]}",
+			v:    map[string]any{"a": []any{1}},
+			want: "{\"a\": [\n  1\n]}",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := Patch([]byte(tt.doc), tt.v)
+		if err != nil {
+			t.Fatalf("%s: Patch failed: %v", tt.name, err)
+		}
+
+		if string(got) != tt.want {
+			t.Fatalf("%s: expected\n%s\ngot\n%s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestPatchSyntaxError(t *testing.T) {
+	if _, err := Patch([]byte(`{"a": }`), map[string]any{}); err == nil {
+		t.Fatalf("expected an error for an invalid document")
+	}
+}
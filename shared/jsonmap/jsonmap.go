@@ -126,6 +126,48 @@ func Assign(target JsonMap, sources ...JsonMap) (JsonMap, error) {
 	return target, nil
 }
 
+// DeepAssign copies all properties from the sources to target like Assign, but merges nested
+// objects key by key instead of replacing them. Nested objects of the sources are copied, so
+// later changes to target don't change the sources.
+func DeepAssign(target JsonMap, sources ...JsonMap) (JsonMap, error) {
+	if target == nil {
+		return nil, os.ErrInvalid
+	}
+	for _, src := range sources {
+		deepAssign(target, src)
+	}
+	return target, nil
+}
+
+func deepAssign(target, src map[string]any) {
+	for key, value := range src {
+		m, ok := asMap(value)
+		if !ok {
+			target[key] = value
+			continue
+		}
+
+		t, ok := asMap(target[key])
+		if !ok {
+			t = map[string]any{}
+			target[key] = t
+		}
+
+		deepAssign(t, m)
+	}
+}
+
+func asMap(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		return m, true
+	case JsonMap:
+		return m, true
+	default:
+		return nil, false
+	}
+}
+
 func (jm JsonMap) ToStruct(s any) error {
 	if s == nil {
 		return nil
@@ -51,6 +51,25 @@ func TestAssign(t *testing.T) {
 	}
 }
 
+func TestDeepAssign(t *testing.T) {
+	src1 := JsonMap{"options": map[string]any{"temperature": 0.2, "top_k": 40}, "model": "a"}
+	src2 := JsonMap{"options": map[string]any{"temperature": 0.8}, "model": "b"}
+
+	jm, err := DeepAssign(JsonMap{}, src1, src2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := jm["options"].(map[string]any)
+	if jm["model"] != "b" || options["temperature"] != 0.8 || options["top_k"] != 40 {
+		t.Fatalf("DeepAssign failed: %v", jm)
+	}
+
+	if src1["options"].(map[string]any)["temperature"] != 0.2 {
+		t.Fatalf("DeepAssign changed a source: %v", src1)
+	}
+}
+
 func TestGet(t *testing.T) {
 	jm := JsonMap{"foo": "bar", "num": float64(42)}
 	val, ok := Get[string](jm, "foo")
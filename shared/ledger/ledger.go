@@ -0,0 +1,167 @@
+package ledger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Entry is a single request recorded in the ledger.
+type Entry struct {
+	Time             time.Time `json:"time"`
+	Profile          string    `json:"profile"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	FirstTokenMs     int64     `json:"first_token_ms"`
+	LatencyMs        int64     `json:"latency_ms"`
+	Error            string    `json:"error,omitempty"`
+	Prompt           string    `json:"prompt,omitempty"`
+}
+
+// Price is the cost of a model in an arbitrary currency per million tokens.
+type Price struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+// Cost returns the estimated cost of the given number of tokens.
+func (p Price) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.InputPerMillion + float64(completionTokens)*p.OutputPerMillion) / 1e6
+}
+
+// Append adds the entry to the ledger file at path, creating it if needed.
+func Append(path string, entry Entry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// Read returns all entries in the ledger file at path. A missing file is an empty ledger.
+func Read(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
+
+const (
+	BY_DAY     = "day"
+	BY_PROFILE = "profile"
+	BY_MODEL   = "model"
+)
+
+// Summary aggregates the entries that share the same key.
+type Summary struct {
+	Key              string  `json:"key"`
+	Requests         int     `json:"requests"`
+	Errors           int     `json:"errors"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	AvgLatencyMs     int64   `json:"avg_latency_ms"`
+	Cost             float64 `json:"cost,omitempty"`
+	// Priced is false when the cost of some entries is unknown
+	Priced bool `json:"priced"`
+}
+
+// Summarize groups entries by day, profile or model. Prices are looked up by model.
+func Summarize(entries []Entry, by string, prices map[string]Price) ([]Summary, error) {
+
+	key := func(e Entry) string { return e.Time.Local().Format("2006-01-02") }
+
+	switch by {
+	case BY_DAY:
+	case BY_PROFILE:
+		key = func(e Entry) string { return e.Profile }
+	case BY_MODEL:
+		key = func(e Entry) string { return e.Provider + "/" + e.Model }
+	default:
+		return nil, fmt.Errorf("unknown grouping %q, expected one of: %s, %s, %s", by, BY_DAY, BY_PROFILE, BY_MODEL)
+	}
+
+	groups := map[string]*Summary{}
+	latency := map[string]int64{}
+
+	for _, e := range entries {
+		k := key(e)
+
+		s, ok := groups[k]
+		if !ok {
+			s = &Summary{Key: k, Priced: true}
+			groups[k] = s
+		}
+
+		s.Requests++
+		s.PromptTokens += e.PromptTokens
+		s.CompletionTokens += e.CompletionTokens
+		latency[k] += e.LatencyMs
+
+		if e.Error != "" {
+			s.Errors++
+		}
+
+		if price, ok := prices[e.Model]; ok {
+			s.Cost += price.Cost(e.PromptTokens, e.CompletionTokens)
+		} else if e.PromptTokens+e.CompletionTokens > 0 {
+			s.Priced = false
+		}
+	}
+
+	summaries := make([]Summary, 0, len(groups))
+	for k, s := range groups {
+		s.AvgLatencyMs = latency[k] / int64(s.Requests)
+		summaries = append(summaries, *s)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Key < summaries[j].Key
+	})
+
+	return summaries, nil
+}
@@ -0,0 +1,86 @@
+package ledger
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "usage.ndjson")
+
+	entries, err := Read(path)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected empty ledger, got %v, %v", entries, err)
+	}
+
+	first := Entry{Time: time.Now().UTC().Truncate(time.Second), Profile: "default", Provider: "ollama", Model: "llama3.2", PromptTokens: 10, CompletionTokens: 20}
+	second := Entry{Time: time.Now().UTC().Truncate(time.Second), Profile: "work", Provider: "github", Model: "gpt-4o", Error: "timeout"}
+
+	for _, e := range []Entry{first, second} {
+		if err := Append(path, e); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	entries, err = Read(path)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if len(entries) != 2 || entries[0] != first || entries[1] != second {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	day1 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	day2 := time.Date(2026, 10, 2, 12, 0, 0, 0, time.Local)
+
+	entries := []Entry{
+		{Time: day1, Profile: "default", Provider: "github", Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 2000, LatencyMs: 100},
+		{Time: day1, Profile: "local", Provider: "ollama", Model: "llama3.2", PromptTokens: 10, CompletionTokens: 20, LatencyMs: 300},
+		{Time: day2, Profile: "default", Provider: "github", Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 0, LatencyMs: 200, Error: "timeout"},
+	}
+
+	prices := map[string]Price{
+		"gpt-4o": {InputPerMillion: 2.5, OutputPerMillion: 10},
+	}
+
+	byProfile, err := Summarize(entries, BY_PROFILE, prices)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+
+	if len(byProfile) != 2 {
+		t.Fatalf("expected 2 groups, got %+v", byProfile)
+	}
+
+	def := byProfile[0]
+	if def.Key != "default" || def.Requests != 2 || def.Errors != 1 || def.PromptTokens != 2000 || def.AvgLatencyMs != 150 || !def.Priced {
+		t.Fatalf("unexpected summary: %+v", def)
+	}
+
+	// 2000 * 2.5 / 1e6 + 2000 * 10 / 1e6
+	if def.Cost < 0.02499 || def.Cost > 0.02501 {
+		t.Fatalf("unexpected cost: %f", def.Cost)
+	}
+
+	if byProfile[1].Priced {
+		t.Fatal("expected local profile to be unpriced")
+	}
+
+	byDay, _ := Summarize(entries, BY_DAY, prices)
+	if len(byDay) != 2 || byDay[0].Key != "2026-10-01" || byDay[0].Requests != 2 {
+		t.Fatalf("unexpected summary by day: %+v", byDay)
+	}
+
+	byModel, _ := Summarize(entries, BY_MODEL, prices)
+	if len(byModel) != 2 || byModel[0].Key != "github/gpt-4o" {
+		t.Fatalf("unexpected summary by model: %+v", byModel)
+	}
+
+	if _, err := Summarize(entries, "week", prices); err == nil {
+		t.Fatal("expected error for unknown grouping")
+	}
+}
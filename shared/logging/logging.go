@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Log levels accepted by --log-level
+const (
+	LEVEL_DEBUG = "debug"
+	LEVEL_INFO  = "info"
+	LEVEL_WARN  = "warn"
+	LEVEL_ERROR = "error"
+)
+
+// ParseLevel converts a level name to a slog level.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case LEVEL_DEBUG:
+		return slog.LevelDebug, nil
+	case LEVEL_INFO:
+		return slog.LevelInfo, nil
+	case "", LEVEL_WARN, "warning":
+		return slog.LevelWarn, nil
+	case LEVEL_ERROR:
+		return slog.LevelError, nil
+	}
+
+	return 0, fmt.Errorf("invalid log level %q, expected one of: %s, %s, %s, %s", name, LEVEL_DEBUG, LEVEL_INFO, LEVEL_WARN, LEVEL_ERROR)
+}
+
+// New creates a logger writing text records at or above level to w.
+// Secrets are redacted from every record, see Redact.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	}))
+}
+
+// Discard returns a logger that drops every record.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// OpenFile opens path for appending log records.
+func OpenFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+}
+
+// Enabled reports whether the logger writes records at level.
+func Enabled(logger *slog.Logger, level slog.Level) bool {
+	return logger.Enabled(context.Background(), level)
+}
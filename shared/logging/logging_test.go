@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := map[string]string{
+		`{"token":"gho_abc","model":"gpt-4"}`:           `{"token":"[REDACTED]","model":"gpt-4"}`,
+		`{"access_token": "x\"y", "scope": ""}`:         `{"access_token": "[REDACTED]", "scope": ""}`,
+		`client_id=Iv1&device_code=abc123&grant_type=x`: `client_id=Iv1&device_code=[REDACTED]&grant_type=x`,
+		`Bearer eyJhbGciOiJIUzI1NiJ9.payload`:           `Bearer [REDACTED]`,
+		`using ghu_0123456789abcdefABCDEF for auth`:     `using [REDACTED] for auth`,
+		`nothing to see here`:                           `nothing to see here`,
+		`Authorization: Basic dXNlcjpwYXNz`:             `Authorization: Basic [REDACTED]`,
+		`curl -H "Authorization: token gho_x" api`:      `curl -H "Authorization: token [REDACTED]" api`,
+		// Ordinary text is left alone
+		`Bearer tokens and basic authentication`:  `Bearer tokens and basic authentication`,
+		`models predict token sequences`:          `models predict token sequences`,
+		`Basic authentication sends the password`: `Basic authentication sends the password`,
+		`{"key":"enter","value":1}`:               `{"key":"enter","value":1}`,
+		`/search?key=home&page=2`:                 `/search?key=home&page=2`,
+	}
+
+	for in, want := range tests {
+		if got := Redact(in); got != want {
+			t.Fatalf("Redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLoggerRedactsAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelDebug)
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret-value-1234")
+	header.Set("Accept", "application/json")
+
+	logger.Debug("test", "token", "plain-secret", "headers", header, "config", JSON(map[string]string{"token": "abc", "model": "m"}))
+
+	out := buf.String()
+
+	if strings.Contains(out, "secret") || strings.Contains(out, "abc") {
+		t.Fatalf("secret leaked into log: %s", out)
+	}
+	if !strings.Contains(out, "application/json") || !strings.Contains(out, "model") {
+		t.Fatalf("expected non-secret values in log: %s", out)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	var buf bytes.Buffer
+
+	level, err := ParseLevel("warn")
+	if err != nil {
+		t.Fatalf("ParseLevel failed: %v", err)
+	}
+
+	logger := New(&buf, level)
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Fatalf("unexpected log output: %s", buf.String())
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatal("expected error for invalid level")
+	}
+}
+
+func TestTransportTracesRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"token":"tid=secret","answer":"hello"}`)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	client := &http.Client{Transport: NewTransport(nil, New(&buf, slog.LevelDebug))}
+
+	req, _ := http.NewRequest("POST", srv.URL+"/chat", strings.NewReader(`{"prompt":"hi"}`))
+	req.Header.Set("Authorization", "Bearer very-secret-token")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if !strings.Contains(string(body), "tid=secret") {
+		t.Fatalf("response body was modified: %s", body)
+	}
+
+	out := buf.String()
+
+	for _, want := range []string{"http request", "POST", "/chat", `prompt`, "http response", "status=200", "http response body", "answer"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in trace: %s", want, out)
+		}
+	}
+
+	if strings.Contains(out, "very-secret-token") || strings.Contains(out, "tid=secret") {
+		t.Fatalf("secret leaked into trace: %s", out)
+	}
+}
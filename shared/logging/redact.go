@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const REDACTED = "[REDACTED]"
+
+// secretKeys are attribute, header, JSON and query parameter names whose values are never logged.
+var secretKeys = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+	"api-key":             true,
+	"api_key":             true,
+	"apikey":              true,
+	"token":               true,
+	"access_token":        true,
+	"refresh_token":       true,
+	"device_code":         true,
+	"client_secret":       true,
+	"password":            true,
+	"secret":              true,
+}
+
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// "token": "value" in JSON
+	{regexp.MustCompile(`(?i)("(?:token|access_token|refresh_token|api_?key|device_code|client_secret|password|secret)"\s*:\s*)"(?:[^"\\]|\\.)*"`), `$1"` + REDACTED + `"`},
+	// token=value in query strings and form bodies
+	{regexp.MustCompile(`(?i)\b((?:token|access_token|refresh_token|api_?key|device_code|client_secret|password|secret)=)[^&\s"]+`), `${1}` + REDACTED},
+	// Authorization: Bearer value, as in a dumped header
+	{regexp.MustCompile(`(?i)(\bauthorization["']?\s*[:=]\s*["']?(?:bearer|basic|token)\s+)[^\s"',]+`), `${1}` + REDACTED},
+	// Bearer and Basic credentials elsewhere, long enough not to be a word of prose
+	{regexp.MustCompile(`\b(Bearer|Basic)\s+[A-Za-z0-9._~+/-]{20,}=*`), `$1 ` + REDACTED},
+	// Well known token formats: GitHub, OpenAI style keys
+	{regexp.MustCompile(`\b(?:gh[pousr]|github_pat)_[A-Za-z0-9_]{16,}`), REDACTED},
+	{regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`), REDACTED},
+}
+
+// IsSecretKey reports whether values stored under name, such as a JSON key or a header, are secret.
+func IsSecretKey(name string) bool {
+	return secretKeys[strings.ToLower(name)]
+}
+
+// Redact replaces tokens, keys and passwords in s.
+func Redact(s string) string {
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// RedactHeader returns a copy of h without secret header values.
+func RedactHeader(h http.Header) http.Header {
+	redacted := make(http.Header, len(h))
+
+	for k, values := range h {
+		if IsSecretKey(k) {
+			redacted[k] = []string{REDACTED}
+			continue
+		}
+
+		redacted[k] = make([]string, len(values))
+		for i, v := range values {
+			redacted[k][i] = Redact(v)
+		}
+	}
+
+	return redacted
+}
+
+// JSON returns v encoded as JSON with secrets redacted, for logging structured values
+// such as configs.
+func JSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return Redact(string(data))
+}
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if IsSecretKey(a.Key) {
+		return slog.String(a.Key, REDACTED)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		if h, ok := a.Value.Any().(http.Header); ok {
+			return slog.Any(a.Key, RedactHeader(h))
+		}
+	}
+
+	return a
+}
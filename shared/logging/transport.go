@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// MAX_BODY_EXCERPT is the number of body bytes included in a trace.
+const MAX_BODY_EXCERPT = 1024
+
+// Transport traces every request and response at debug level.
+type Transport struct {
+	next   http.RoundTripper
+	logger *slog.Logger
+}
+
+// NewTransport wraps next, which defaults to http.DefaultTransport.
+func NewTransport(next http.RoundTripper, logger *slog.Logger) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Transport{next: next, logger: logger}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !Enabled(t.logger, slog.LevelDebug) {
+		return t.next.RoundTrip(req)
+	}
+
+	attrs := []any{
+		"method", req.Method,
+		"url", req.URL.String(),
+		"headers", req.Header,
+	}
+
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			attrs = append(attrs, "body", excerpt(body))
+			body.Close()
+		}
+	}
+
+	t.logger.Debug("http request", attrs...)
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.logger.Debug("http error", "method", req.Method, "url", req.URL.String(), "duration", elapsed, "error", err.Error())
+		return resp, err
+	}
+
+	t.logger.Debug("http response",
+		"method", req.Method,
+		"url", req.URL.String(),
+		"status", resp.StatusCode,
+		"duration", elapsed,
+		"headers", resp.Header,
+	)
+
+	// The body is streamed to the caller, its beginning is logged once it is closed
+	resp.Body = &tracedBody{
+		ReadCloser: resp.Body,
+		logger:     t.logger,
+		url:        req.URL.String(),
+		start:      start,
+	}
+
+	return resp, nil
+}
+
+func excerpt(r io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(r, MAX_BODY_EXCERPT+1))
+	return truncate(data)
+}
+
+func truncate(data []byte) string {
+	if len(data) > MAX_BODY_EXCERPT {
+		return string(data[:MAX_BODY_EXCERPT]) + "..."
+	}
+	return string(data)
+}
+
+// tracedBody keeps the first bytes read from a response body.
+type tracedBody struct {
+	io.ReadCloser
+	logger *slog.Logger
+	url    string
+	start  time.Time
+	buf    bytes.Buffer
+	size   int64
+	closed bool
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	if room := MAX_BODY_EXCERPT + 1 - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(n, room)])
+	}
+	b.size += int64(n)
+
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		b.logger.Debug("http response body",
+			"url", b.url,
+			"bytes", b.size,
+			"duration", time.Since(b.start),
+			"body", truncate(b.buf.Bytes()),
+		)
+	}
+
+	return b.ReadCloser.Close()
+}
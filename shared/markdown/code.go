@@ -0,0 +1,25 @@
+package markdown
+
+import "strings"
+
+// ExtractCode returns the contents of the first fenced code block in text.
+// If text contains no code block, the trimmed text itself is returned.
+func ExtractCode(text string) string {
+	lines := strings.Split(text, "\n")
+
+	start := -1
+	for i, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "```") {
+			continue
+		}
+
+		if start == -1 {
+			start = i + 1
+			continue
+		}
+
+		return strings.Join(lines[start:i], "\n")
+	}
+
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,24 @@
+package markdown
+
+import "testing"
+
+func TestExtractCode(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "  nmap -p 22 192.168.6.1\n", "nmap -p 22 192.168.6.1"},
+		{"fenced", "Run this:\n```bash\nls -la\ncd /tmp\n```\nDone.", "ls -la\ncd /tmp"},
+		{"first block wins", "```\nfirst\n```\n```\nsecond\n```", "first"},
+		{"unterminated", "```\nls", "```\nls"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractCode(tt.text); got != tt.want {
+				t.Fatalf("ExtractCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
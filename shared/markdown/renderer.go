@@ -13,11 +13,12 @@ type MarkdownRenderer struct {
 	style    string
 }
 
-// NewMarkdownRenderer creates a new markdown renderer
-func NewMarkdownRenderer(style string) (*MarkdownRenderer, error) {
+// NewMarkdownRenderer creates a new markdown renderer. The style is either the name
+// of a glamour style or the path of a JSON style file.
+func NewMarkdownRenderer(style string, wordWrap int) (*MarkdownRenderer, error) {
 	r, err := glamour.NewTermRenderer(
 		glamour.WithStylePath(style),
-		glamour.WithWordWrap(80),
+		glamour.WithWordWrap(wordWrap),
 	)
 	if err != nil {
 		return nil, err
@@ -1,7 +1,17 @@
 package provider
 
+import (
+	"log/slog"
+
+	"github.com/mcnull/qai/shared/httpclient"
+	"github.com/mcnull/qai/shared/ui"
+)
+
 type AppContext struct {
 	Flags        *FlagValues
 	Provider     IProvider
 	SystemPrompt string
+	HTTPClient   *httpclient.Client
+	UI           *ui.UI
+	Logger       *slog.Logger
 }
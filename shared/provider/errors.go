@@ -0,0 +1,235 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// ErrorClass groups provider errors by how a caller might react to them.
+type ErrorClass int
+
+const (
+	ErrorClassUnknown    ErrorClass = iota
+	ErrorClassAuth                  // missing, expired or invalid credentials
+	ErrorClassRateLimit             // too many requests
+	ErrorClassConnection            // the provider could not be reached
+	ErrorClassTimeout               // the provider did not answer in time
+	ErrorClassRequest               // the provider rejected the request (bad model, bad input)
+	ErrorClassServer                // the provider failed to handle the request
+	ErrorClassCancelled             // the request was cancelled by the user
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorClassAuth:
+		return "auth"
+	case ErrorClassRateLimit:
+		return "rate_limit"
+	case ErrorClassConnection:
+		return "connection"
+	case ErrorClassTimeout:
+		return "timeout"
+	case ErrorClassRequest:
+		return "request"
+	case ErrorClassServer:
+		return "server"
+	case ErrorClassCancelled:
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseErrorClass returns the class with the given name, see ErrorClass.String.
+func ParseErrorClass(name string) (ErrorClass, bool) {
+	for c := ErrorClassAuth; c <= ErrorClassCancelled; c++ {
+		if c.String() == name {
+			return c, true
+		}
+	}
+	return ErrorClassUnknown, false
+}
+
+// Error is returned by providers when a request fails.
+type Error struct {
+	Provider  string
+	Class     ErrorClass
+	Status    int    // HTTP status code, 0 when no response was received
+	Code      string // error code reported by the API, if any
+	Message   string
+	Retryable bool
+	Hint      string // suggestion for the user on how to resolve the error
+	Err       error  // underlying error, if any
+}
+
+func (e *Error) Error() string {
+	var sb strings.Builder
+
+	if e.Provider != "" {
+		sb.WriteString(e.Provider)
+		sb.WriteString(": ")
+	}
+
+	msg := e.Message
+	if msg == "" && e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if msg == "" && e.Status != 0 {
+		msg = http.StatusText(e.Status)
+	}
+	sb.WriteString(msg)
+
+	switch {
+	case e.Status != 0 && e.Code != "":
+		fmt.Fprintf(&sb, " (status %d, %s)", e.Status, e.Code)
+	case e.Status != 0:
+		fmt.Fprintf(&sb, " (status %d)", e.Status)
+	case e.Code != "":
+		fmt.Fprintf(&sb, " (%s)", e.Code)
+	}
+
+	return sb.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// AsError returns the first *Error in err's chain.
+func AsError(err error) (*Error, bool) {
+	var pErr *Error
+	if errors.As(err, &pErr) {
+		return pErr, true
+	}
+	return nil, false
+}
+
+// NewStatusError creates an error for a non-successful HTTP response.
+// The body is parsed for the common API error shapes:
+//
+//	{"error": "message"}
+//	{"error": {"message": "...", "code": "...", "type": "..."}}
+//	{"message": "..."}
+//
+// and used as-is when it is not JSON.
+func NewStatusError(providerName string, status int, body []byte) *Error {
+	code, message := ParseErrorBody(body)
+
+	return &Error{
+		Provider:  providerName,
+		Class:     ClassifyStatus(status),
+		Status:    status,
+		Code:      code,
+		Message:   message,
+		Retryable: status == http.StatusTooManyRequests || status >= 500,
+	}
+}
+
+// NewRequestError creates an error for a request that did not get a response.
+func NewRequestError(providerName string, err error) *Error {
+	e := &Error{
+		Provider: providerName,
+		Class:    ErrorClassConnection,
+		Err:      err,
+	}
+
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		e.Class = ErrorClassCancelled
+	case errors.Is(err, context.DeadlineExceeded):
+		e.Class = ErrorClassTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		e.Class = ErrorClassTimeout
+		e.Retryable = true
+	default:
+		e.Retryable = true
+	}
+
+	return e
+}
+
+// IsConnectionRefused reports whether err was caused by a refused connection.
+func IsConnectionRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// ClassifyStatus maps an HTTP status code to an error class.
+func ClassifyStatus(status int) ErrorClass {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorClassAuth
+	case status == http.StatusTooManyRequests:
+		return ErrorClassRateLimit
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return ErrorClassTimeout
+	case status >= 500:
+		return ErrorClassServer
+	case status >= 400:
+		return ErrorClassRequest
+	default:
+		return ErrorClassUnknown
+	}
+}
+
+// ParseErrorBody extracts an error code and message from an API error response body.
+func ParseErrorBody(body []byte) (code string, message string) {
+	text := strings.TrimSpace(string(body))
+
+	var v struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+		Code    any             `json:"code"`
+	}
+
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", text
+	}
+
+	code = codeString(v.Code)
+	message = v.Message
+
+	if len(v.Error) > 0 {
+		var s string
+		if err := json.Unmarshal(v.Error, &s); err == nil {
+			message = s
+		} else {
+			var obj struct {
+				Message string `json:"message"`
+				Code    any    `json:"code"`
+				Type    string `json:"type"`
+			}
+			if err := json.Unmarshal(v.Error, &obj); err == nil {
+				message = obj.Message
+				code = codeString(obj.Code)
+				if code == "" {
+					code = obj.Type
+				}
+			}
+		}
+	}
+
+	if message == "" {
+		message = text
+	}
+
+	return code, message
+}
+
+func codeString(v any) string {
+	switch c := v.(type) {
+	case string:
+		return c
+	case float64:
+		return fmt.Sprintf("%g", c)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+)
+
+func TestParseErrorBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    string
+		wantMessage string
+	}{
+		{"ollama", `{"error":"model 'llama9' not found"}`, "", "model 'llama9' not found"},
+		{"openai", `{"error":{"message":"quota exceeded","type":"insufficient_quota","code":"rate_limited"}}`, "rate_limited", "quota exceeded"},
+		{"openai type only", `{"error":{"message":"bad model","type":"invalid_request_error"}}`, "invalid_request_error", "bad model"},
+		{"github api", `{"message":"Bad credentials","documentation_url":"https://docs.github.com"}`, "", "Bad credentials"},
+		{"plain text", "unauthorized: token expired\n", "", "unauthorized: token expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, message := ParseErrorBody([]byte(tt.body))
+			if code != tt.wantCode || message != tt.wantMessage {
+				t.Fatalf("ParseErrorBody() = (%q, %q), want (%q, %q)", code, message, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestNewStatusError(t *testing.T) {
+	err := NewStatusError("github", 401, []byte(`{"error":{"message":"token expired","code":"unauthorized"}}`))
+
+	if err.Class != ErrorClassAuth || err.Retryable {
+		t.Fatalf("unexpected classification: %+v", err)
+	}
+	if got, want := err.Error(), "github: token expired (status 401, unauthorized)"; got != want {
+		t.Fatalf("Error() = %q, want %q", got, want)
+	}
+
+	err = NewStatusError("ollama", 503, nil)
+	if err.Class != ErrorClassServer || !err.Retryable {
+		t.Fatalf("unexpected classification: %+v", err)
+	}
+	if got, want := err.Error(), "ollama: Service Unavailable (status 503)"; got != want {
+		t.Fatalf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestNewRequestError(t *testing.T) {
+	refused := fmt.Errorf("dial tcp: %w", syscall.ECONNREFUSED)
+
+	err := NewRequestError("ollama", refused)
+	if err.Class != ErrorClassConnection || !IsConnectionRefused(err) {
+		t.Fatalf("unexpected classification: %+v", err)
+	}
+
+	if err := NewRequestError("ollama", context.Canceled); err.Class != ErrorClassCancelled {
+		t.Fatalf("expected cancelled, got %v", err.Class)
+	}
+
+	if err := NewRequestError("ollama", context.DeadlineExceeded); err.Class != ErrorClassTimeout {
+		t.Fatalf("expected timeout, got %v", err.Class)
+	}
+
+	wrapped := fmt.Errorf("outer: %w", err)
+	if pErr, ok := AsError(wrapped); !ok || pErr != err {
+		t.Fatal("AsError did not find the provider error")
+	}
+
+	if !errors.Is(fmt.Errorf("outer: %w", NewRequestError("ollama", refused)), syscall.ECONNREFUSED) {
+		t.Fatal("underlying error is not reachable through Unwrap")
+	}
+}
@@ -3,6 +3,10 @@ package provider
 import (
 	"flag"
 	"fmt"
+	"time"
+
+	"github.com/mcnull/qai/shared/httpclient"
+	"github.com/mcnull/qai/shared/terminal"
 )
 
 type FlagValues struct {
@@ -10,13 +14,29 @@ type FlagValues struct {
 	CreateConfig bool
 	Profile      string
 	Prompt       string
+	Args         []string // Positional arguments, joined to form the prompt
 	Debug        bool
 	DebugStream  bool
 	System       string
 	Verbose      bool
-	Color        bool
+	Color        string
+	Style        string
 	GithubLogin  bool
 	Version      bool
+	Copy         bool
+	Retries      int
+	Output       string
+	Schema       string
+	Stats        bool
+	LogFile      string
+	LogLevel     string
+	Record       string
+	Replay       string
+	Compare      string // Comma separated profiles that are asked at once
+	// Nil when not set, the profile setting or the built-in default is used. Zero
+	// disables the timeout, like in a profile
+	Timeout           *time.Duration
+	FirstTokenTimeout *time.Duration
 }
 
 func NewFlagValues(configFile, system string) *FlagValues {
@@ -29,9 +49,20 @@ func NewFlagValues(configFile, system string) *FlagValues {
 		DebugStream:  false,
 		System:       system,
 		Verbose:      false,
-		Color:        true,
+		Color:        "auto",
+		Style:        "",
 		GithubLogin:  false,
 		Version:      false,
+		Copy:         false,
+		Retries:      httpclient.DEFAULT_MAX_RETRIES,
+		Output:       "",
+		Schema:       "",
+		Stats:        false,
+		LogFile:      "",
+		LogLevel:     "warn",
+		Record:       "",
+		Replay:       "",
+		Compare:      "",
 	}
 }
 
@@ -46,20 +77,74 @@ func CreateFlagSet(name string, v *FlagValues, exitOnError bool) *flag.FlagSet {
 	fs := flag.NewFlagSet(name, exitRule)
 
 	fs.Usage = func() {
-		fmt.Fprintf(fs.Output(), "Usage: %s [options] (prompt)\n\nOptions:\n", name)
+		fmt.Fprintf(fs.Output(), "Usage: %s [options] (prompt)\n       %s [options] usage [-by day|profile|model] [-days n]\n       %s [options] profiles\n       %s [options] config validate|get|set|edit|show|sources|schema\n\nOptions:\n", name, name, name, name)
 		fs.PrintDefaults()
 	}
 
 	fs.StringVar(&v.ConfigFile, "config", v.ConfigFile, "Path to the config file")
 	fs.BoolVar(&v.CreateConfig, "create-config", v.CreateConfig, "Create a new config file with default values")
 	fs.StringVar(&v.Profile, "profile", v.Profile, "Profile name")
-	fs.StringVar(&v.System, "system", v.System, "System prompt")
+	fs.StringVar(&v.Compare, "compare", v.Compare, "Ask several comma separated profiles at once and compare their answers, e.g. local,copilot")
+	fs.StringVar(&v.System, "system", v.System, "System prompt (default: the system prompt of the config)")
 	fs.BoolVar(&v.Debug, "debug", v.Debug, "Enable debug mode")
 	fs.BoolVar(&v.DebugStream, "debug-stream", v.DebugStream, "Enable debug response stream")
-	fs.BoolVar(&v.Color, "color", v.Color, "Enable colored output")
+	fs.StringVar(&v.LogFile, "log-file", v.LogFile, "Write log records to a file instead of stderr")
+	fs.StringVar(&v.LogLevel, "log-level", v.LogLevel, "Log level: debug, info, warn or error (-debug sets debug)")
+	fs.StringVar(&v.Record, "record", v.Record, "Record all HTTP interactions to a cassette file in this directory")
+	fs.StringVar(&v.Replay, "replay", v.Replay, "Answer the HTTP requests from a cassette file instead of the network")
+	fs.Var(colorFlag{&v.Color}, "color", "Colored output: auto, always or never, a bare -color means always")
+	fs.StringVar(&v.Style, "style", v.Style, "Markdown style: a glamour style name such as dark, light or dracula, or the path of a JSON style file (default depends on the terminal background)")
+	fs.BoolVar(&v.Stats, "stats", v.Stats, "Print token usage and timing statistics to stderr")
+	fs.StringVar(&v.Schema, "schema", v.Schema, "Path to a JSON Schema the answer must conform to")
+	fs.StringVar(&v.Output, "output", v.Output, "Output format: raw, markdown, json or ndjson (default markdown when color is enabled, raw otherwise)")
 	fs.BoolVar(&v.Verbose, "verbose", v.Verbose, "Enable verbose output")
 	fs.BoolVar(&v.GithubLogin, "github-login", v.GithubLogin, "Create a new GitHub auth token")
 	fs.BoolVar(&v.Version, "version", v.Version, "Show version information")
+	fs.BoolVar(&v.Copy, "copy", v.Copy, "Copy the suggested command to the clipboard")
+	fs.IntVar(&v.Retries, "retries", v.Retries, "Number of retries on transient network errors")
+	fs.Func("timeout", "Maximum time for the whole request as a `duration` such as 30s or 10m, 0 disables it (default: the profile setting or 5m)", durationFlag(&v.Timeout))
+	fs.Func("first-token-timeout", "Maximum `duration` to wait for the first and each following chunk of the response, 0 disables it (default: the profile setting)", durationFlag(&v.FirstTokenTimeout))
 
 	return fs
 }
+
+// durationFlag returns the setter of an optional duration flag, which stays nil unless
+// the flag is given.
+func durationFlag(d **time.Duration) func(string) error {
+	return func(s string) error {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+
+		*d = &v
+		return nil
+	}
+}
+
+// colorFlag is the -color flag. It takes a color mode but is a boolean flag, so a bare
+// -color still enables colors like it did before the modes were added.
+type colorFlag struct {
+	mode *string
+}
+
+func (c colorFlag) String() string {
+	if c.mode == nil {
+		return ""
+	}
+	return *c.mode
+}
+
+func (c colorFlag) Set(s string) error {
+	mode, err := terminal.ParseColorMode(s)
+	if err != nil {
+		return err
+	}
+
+	*c.mode = mode
+	return nil
+}
+
+func (c colorFlag) IsBoolFlag() bool {
+	return true
+}
@@ -1,6 +1,13 @@
 package provider
 
-import "context"
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mcnull/qai/shared/httpclient"
+	"github.com/mcnull/qai/shared/logging"
+	"github.com/mcnull/qai/shared/ui"
+)
 
 type IProvider interface {
 	GetName() string
@@ -8,6 +15,18 @@ type IProvider interface {
 	Generate(ctx context.Context, request GenerateRequest) (<-chan GenerateResponse, <-chan error)
 }
 
+// ISchemaProvider is implemented by providers whose API constrains the answer to the
+// JSON Schema of the request.
+type ISchemaProvider interface {
+	EnforcesSchema() bool
+}
+
+// EnforcesSchema reports whether p constrains the answer to the schema of a request itself.
+func EnforcesSchema(p IProvider) bool {
+	s, ok := p.(ISchemaProvider)
+	return ok && s.EnforcesSchema()
+}
+
 type ProviderBase struct {
 	Name       string
 	AppContext *AppContext
@@ -33,3 +52,27 @@ func (p *ProviderBase) Init() error {
 func (p *ProviderBase) Flags() *FlagValues {
 	return p.AppContext.Flags
 }
+
+// HTTPClient returns the client providers should use for their requests.
+func (p *ProviderBase) HTTPClient() *httpclient.Client {
+	if p.AppContext == nil || p.AppContext.HTTPClient == nil {
+		return httpclient.Default()
+	}
+	return p.AppContext.HTTPClient
+}
+
+// UI returns where providers should write status and diagnostics.
+func (p *ProviderBase) UI() *ui.UI {
+	if p.AppContext == nil || p.AppContext.UI == nil {
+		return ui.Default()
+	}
+	return p.AppContext.UI
+}
+
+// Logger returns the logger providers should use for diagnostics.
+func (p *ProviderBase) Logger() *slog.Logger {
+	if p.AppContext == nil || p.AppContext.Logger == nil {
+		return logging.Discard()
+	}
+	return p.AppContext.Logger
+}
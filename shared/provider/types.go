@@ -1,14 +1,88 @@
 package provider
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type GenerateRequest struct {
 	System string
 	Prompt string
+	Stream bool
+	// Schema is a JSON Schema the response must conform to, nil for free text
+	Schema json.RawMessage
 }
 
 type GenerateResponse struct {
 	Raw      any
 	Response string `json:"response"`
 	Done     bool   `json:"done,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Usage    *Usage `json:"usage,omitempty"`
+}
+
+// Usage holds the token counts and timings reported by the provider, set on the final response.
+// Durations are zero when the provider doesn't report them. In JSON they are written in
+// milliseconds, see MarshalJSON.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	TotalDuration    time.Duration
+	LoadDuration     time.Duration
+	PromptDuration   time.Duration
+	EvalDuration     time.Duration
+}
+
+// usageJSON is the JSON representation of Usage.
+type usageJSON struct {
+	PromptTokens     int   `json:"prompt_tokens"`
+	CompletionTokens int   `json:"completion_tokens"`
+	TotalTokens      int   `json:"total_tokens"`
+	TotalDurationMs  int64 `json:"total_duration_ms,omitempty"`
+	LoadDurationMs   int64 `json:"load_duration_ms,omitempty"`
+	PromptDurationMs int64 `json:"prompt_duration_ms,omitempty"`
+	EvalDurationMs   int64 `json:"eval_duration_ms,omitempty"`
+}
+
+// MarshalJSON writes the durations in milliseconds, like the other timings of qai.
+func (u Usage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(usageJSON{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		TotalDurationMs:  u.TotalDuration.Milliseconds(),
+		LoadDurationMs:   u.LoadDuration.Milliseconds(),
+		PromptDurationMs: u.PromptDuration.Milliseconds(),
+		EvalDurationMs:   u.EvalDuration.Milliseconds(),
+	})
+}
+
+func (u *Usage) UnmarshalJSON(data []byte) error {
+	var v usageJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*u = Usage{
+		PromptTokens:     v.PromptTokens,
+		CompletionTokens: v.CompletionTokens,
+		TotalTokens:      v.TotalTokens,
+		TotalDuration:    time.Duration(v.TotalDurationMs) * time.Millisecond,
+		LoadDuration:     time.Duration(v.LoadDurationMs) * time.Millisecond,
+		PromptDuration:   time.Duration(v.PromptDurationMs) * time.Millisecond,
+		EvalDuration:     time.Duration(v.EvalDurationMs) * time.Millisecond,
+	}
+
+	return nil
+}
+
+// TokensPerSecond returns the generation speed as reported by the provider, zero when unknown.
+func (u *Usage) TokensPerSecond() float64 {
+	if u == nil || u.EvalDuration <= 0 {
+		return 0
+	}
+	return float64(u.CompletionTokens) / u.EvalDuration.Seconds()
 }
 
 type ConfigFactory func() IConfig
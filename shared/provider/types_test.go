@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestUsageJSON(t *testing.T) {
+	usage := Usage{
+		PromptTokens:     30,
+		CompletionTokens: 12,
+		TotalTokens:      42,
+		TotalDuration:    1500 * time.Millisecond,
+		EvalDuration:     400 * time.Millisecond,
+	}
+
+	data, err := json.Marshal(&usage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"prompt_tokens":30,"completion_tokens":12,"total_tokens":42,"total_duration_ms":1500,"eval_duration_ms":400}`
+	if string(data) != want {
+		t.Fatalf("expected %s, got %s", want, data)
+	}
+
+	var decoded Usage
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded != usage {
+		t.Fatalf("expected %+v, got %+v", usage, decoded)
+	}
+}
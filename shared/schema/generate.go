@@ -0,0 +1,115 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+)
+
+// DRAFT is the JSON Schema dialect of the generated schemas.
+const DRAFT = "https://json-schema.org/draft/2020-12/schema"
+
+// Provider is implemented by types whose JSON form is not derived from their Go type,
+// such as values with a custom MarshalJSON.
+type Provider interface {
+	JSONSchema() map[string]any
+}
+
+var providerType = reflect.TypeOf((*Provider)(nil)).Elem()
+
+// Generate returns a JSON Schema for the JSON encoding of v. Struct fields are named
+// after their json tags and unknown properties are not allowed. Interface fields are
+// described by the value they hold, so v should be populated with its defaults.
+func Generate(v any) map[string]any {
+	return generate(reflect.ValueOf(v), reflect.TypeOf(v))
+}
+
+func generate(v reflect.Value, t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+
+	if t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface && reflect.PointerTo(t).Implements(providerType) {
+		return reflect.New(t).Interface().(Provider).JSONSchema()
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		if v.IsValid() && !v.IsNil() {
+			return generate(v.Elem(), t.Elem())
+		}
+		return generate(reflect.Value{}, t.Elem())
+
+	case reflect.Interface:
+		if v.IsValid() && !v.IsNil() {
+			return generate(v.Elem(), v.Elem().Type())
+		}
+		return map[string]any{}
+
+	case reflect.Struct:
+		return generateStruct(v, t)
+
+	case reflect.Map:
+		s := map[string]any{"type": "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			s["additionalProperties"] = generate(reflect.Value{}, t.Elem())
+		}
+		return s
+
+	case reflect.Slice, reflect.Array:
+		return map[string]any{
+			"type":  "array",
+			"items": generate(reflect.Value{}, t.Elem()),
+		}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+
+	default:
+		return map[string]any{}
+	}
+}
+
+func generateStruct(v reflect.Value, t reflect.Type) map[string]any {
+	properties := map[string]any{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		var fv reflect.Value
+		if v.IsValid() {
+			fv = v.Field(i)
+		}
+
+		properties[name] = generate(fv, f.Type)
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
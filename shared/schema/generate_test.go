@@ -0,0 +1,55 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type testDuration int
+
+func (testDuration) JSONSchema() map[string]any {
+	return map[string]any{"type": "string"}
+}
+
+type testSettings interface{}
+
+type testConfig struct {
+	Name     string            `json:"name"`
+	Count    *int              `json:"count,omitempty"`
+	Ratio    float64           `json:"ratio"`
+	Tags     []string          `json:"tags"`
+	Timeout  *testDuration     `json:"timeout,omitempty"`
+	Labels   map[string]string `json:"labels"`
+	Extra    map[string]any    `json:"extra"`
+	Settings testSettings      `json:"settings"`
+	Ignored  string            `json:"-"`
+	internal string
+}
+
+type testProviderConfig struct {
+	URL string `json:"url"`
+}
+
+func TestGenerate(t *testing.T) {
+	s := Generate(&testConfig{Settings: &testProviderConfig{}})
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	want := `{"additionalProperties":false,"properties":{` +
+		`"count":{"type":"integer"},` +
+		`"extra":{"type":"object"},` +
+		`"labels":{"additionalProperties":{"type":"string"},"type":"object"},` +
+		`"name":{"type":"string"},` +
+		`"ratio":{"type":"number"},` +
+		`"settings":{"additionalProperties":false,"properties":{"url":{"type":"string"}},"type":"object"},` +
+		`"tags":{"items":{"type":"string"},"type":"array"},` +
+		`"timeout":{"type":"string"}` +
+		`},"type":"object"}`
+
+	if string(b) != want {
+		t.Fatalf("unexpected schema:\n%s\nwant:\n%s", b, want)
+	}
+}
@@ -0,0 +1,166 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/mcnull/qai/shared/jsonc"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Schema is a JSON Schema that is sent to providers and used to validate their answers.
+type Schema struct {
+	// Raw is the schema document as it was loaded.
+	Raw      json.RawMessage
+	compiled *jsonschema.Schema
+}
+
+// Load reads and compiles the JSON Schema at path.
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// Parse compiles the JSON Schema in data.
+func Parse(data []byte) (*Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing schema: %w", err)
+	}
+
+	c := jsonschema.NewCompiler()
+
+	if err := c.AddResource("schema.json", doc); err != nil {
+		return nil, fmt.Errorf("error loading schema: %w", err)
+	}
+
+	compiled, err := c.Compile("schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("error compiling schema: %w", err)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return nil, fmt.Errorf("error parsing schema: %w", err)
+	}
+
+	return &Schema{
+		Raw:      compact.Bytes(),
+		compiled: compiled,
+	}, nil
+}
+
+// Validate checks that text is a JSON document conforming to the schema.
+func (s *Schema) Validate(text string) error {
+	inst, err := jsonschema.UnmarshalJSON(strings.NewReader(text))
+	if err != nil {
+		return fmt.Errorf("answer is not valid JSON: %w", err)
+	}
+
+	if err := s.compiled.Validate(inst); err != nil {
+		return fmt.Errorf("answer does not match the schema: %w", err)
+	}
+
+	return nil
+}
+
+// Issue is a problem found in a document, with the path and position of the offending value.
+// The position is zero when it is unknown.
+type Issue struct {
+	Path     []string
+	Position jsonc.Position
+	Message  string
+}
+
+func (i Issue) String() string {
+	var s strings.Builder
+
+	if i.Position.Line > 0 {
+		s.WriteString(i.Position.String() + ": ")
+	}
+
+	if len(i.Path) > 0 {
+		s.WriteString(strings.Join(i.Path, ".") + ": ")
+	}
+
+	s.WriteString(i.Message)
+
+	return s.String()
+}
+
+// SortIssues orders issues by their position in the document.
+func SortIssues(issues []Issue) {
+	slices.SortStableFunc(issues, func(a, b Issue) int {
+		if a.Position.Line != b.Position.Line {
+			return a.Position.Line - b.Position.Line
+		}
+		return a.Position.Column - b.Position.Column
+	})
+}
+
+// Check validates the document indexed by l and returns every problem found,
+// located in the source document.
+func (s *Schema) Check(l *jsonc.Locator) ([]Issue, error) {
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(l.JSON()))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing document: %w", err)
+	}
+
+	err = s.compiled.Validate(inst)
+	if err == nil {
+		return nil, nil
+	}
+
+	var vErr *jsonschema.ValidationError
+	if !errors.As(err, &vErr) {
+		return nil, err
+	}
+
+	var issues []Issue
+	collectIssues(l, vErr, message.NewPrinter(language.English), &issues)
+	SortIssues(issues)
+
+	return issues, nil
+}
+
+// collectIssues adds an issue for every leaf of the validation error tree.
+func collectIssues(l *jsonc.Locator, e *jsonschema.ValidationError, p *message.Printer, issues *[]Issue) {
+	if len(e.Causes) > 0 {
+		for _, cause := range e.Causes {
+			collectIssues(l, cause, p, issues)
+		}
+		return
+	}
+
+	path := e.InstanceLocation
+
+	if k, ok := e.ErrorKind.(*kind.AdditionalProperties); ok {
+		for _, name := range k.Properties {
+			child := append(path[:len(path):len(path)], name)
+			*issues = append(*issues, Issue{
+				Path:     child,
+				Position: l.Key(child),
+				Message:  "unknown property",
+			})
+		}
+		return
+	}
+
+	*issues = append(*issues, Issue{
+		Path:     path,
+		Position: l.Value(path),
+		Message:  e.ErrorKind.LocalizedString(p),
+	})
+}
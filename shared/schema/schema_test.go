@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/mcnull/qai/shared/jsonc"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"age": {"type": "integer", "minimum": 0}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(personSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if err := s.Validate(`{"name":"Ada","age":36}`); err != nil {
+		t.Fatalf("expected valid document, got %v", err)
+	}
+
+	invalid := []string{
+		`{"name":"Ada"}`,
+		`{"name":"Ada","age":-1}`,
+		`{"name":"Ada","age":36,"extra":true}`,
+		`not json`,
+	}
+
+	for _, doc := range invalid {
+		if err := s.Validate(doc); err == nil {
+			t.Fatalf("expected %s to be rejected", doc)
+		}
+	}
+}
+
+func TestParseInvalidSchema(t *testing.T) {
+	if _, err := Parse([]byte(`{"type": 12}`)); err == nil {
+		t.Fatal("expected error for invalid schema")
+	}
+	if _, err := Parse([]byte(`{`)); err == nil {
+		t.Fatal("expected error for malformed schema")
+	}
+}
+
+func TestRawIsCompact(t *testing.T) {
+	s, err := Parse([]byte("{\n  \"type\": \"string\"\n}"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if string(s.Raw) != `{"type":"string"}` {
+		t.Fatalf("unexpected raw schema: %s", s.Raw)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s, err := Parse([]byte(personSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	doc := "{\n  \"name\": \"Ada\",\n  \"age\": -1,\n  \"extra\": true\n}"
+
+	l, err := jsonc.NewLocator([]byte(doc))
+	if err != nil {
+		t.Fatalf("NewLocator failed: %v", err)
+	}
+
+	issues, err := s.Check(l)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	want := []string{
+		"3:10: age: minimum: got -1, want 0",
+		"4:3: extra: unknown property",
+	}
+
+	if len(issues) != len(want) {
+		t.Fatalf("expected %d issues, got %v", len(want), issues)
+	}
+
+	for i, issue := range issues {
+		if issue.String() != want[i] {
+			t.Fatalf("issue %d: expected %q, got %q", i, want[i], issue.String())
+		}
+	}
+}
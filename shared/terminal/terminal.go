@@ -0,0 +1,94 @@
+package terminal
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/muesli/termenv"
+	"golang.org/x/term"
+)
+
+// Color modes accepted by --color
+const (
+	COLOR_AUTO   = "auto"
+	COLOR_ALWAYS = "always"
+	COLOR_NEVER  = "never"
+)
+
+// DEFAULT_WIDTH is used when the width of the terminal cannot be determined.
+const DEFAULT_WIDTH = 80
+
+// Injected for testing
+var (
+	isTerminal        = func(f *os.File) bool { return term.IsTerminal(int(f.Fd())) }
+	getSize           = func(f *os.File) (int, int, error) { return term.GetSize(int(f.Fd())) }
+	hasDarkBackground = termenv.HasDarkBackground
+)
+
+// IsTerminal reports whether w is a terminal.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// ParseColorMode validates a --color value. The boolean values of the former
+// --color flag are still accepted.
+func ParseColorMode(s string) (string, error) {
+	switch s {
+	case "", COLOR_AUTO:
+		return COLOR_AUTO, nil
+	case COLOR_ALWAYS, "true", "1":
+		return COLOR_ALWAYS, nil
+	case COLOR_NEVER, "false", "0":
+		return COLOR_NEVER, nil
+	}
+
+	return "", fmt.Errorf("invalid color mode %q, expected one of: %s, %s, %s", s, COLOR_AUTO, COLOR_ALWAYS, COLOR_NEVER)
+}
+
+// ColorEnabled decides whether output written to w is colored. In auto mode NO_COLOR
+// disables colors, CLICOLOR_FORCE enables them, and otherwise w must be a terminal.
+// The variables are read with getenv, os.Getenv when nil.
+func ColorEnabled(mode string, w io.Writer, getenv func(string) string) bool {
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+
+	switch mode {
+	case COLOR_ALWAYS:
+		return true
+	case COLOR_NEVER:
+		return false
+	}
+
+	if getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	if force := getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+
+	return IsTerminal(w)
+}
+
+// Width returns the width of the terminal w, or DEFAULT_WIDTH when w is not a terminal.
+func Width(w io.Writer) int {
+	if !IsTerminal(w) {
+		return DEFAULT_WIDTH
+	}
+
+	width, _, err := getSize(w.(*os.File))
+	if err != nil || width <= 0 {
+		return DEFAULT_WIDTH
+	}
+
+	return width
+}
+
+// HasDarkBackground reports whether the terminal has a dark background. Terminals that
+// don't answer the query are assumed to be dark.
+func HasDarkBackground() bool {
+	return hasDarkBackground()
+}
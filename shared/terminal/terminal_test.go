@@ -0,0 +1,87 @@
+package terminal
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func withTerminal(t *testing.T, tty bool) {
+	t.Helper()
+
+	origIsTerminal, origGetSize := isTerminal, getSize
+	t.Cleanup(func() { isTerminal, getSize = origIsTerminal, origGetSize })
+
+	isTerminal = func(*os.File) bool { return tty }
+}
+
+func TestParseColorMode(t *testing.T) {
+	tests := map[string]string{
+		"":       COLOR_AUTO,
+		"auto":   COLOR_AUTO,
+		"always": COLOR_ALWAYS,
+		"true":   COLOR_ALWAYS,
+		"never":  COLOR_NEVER,
+		"false":  COLOR_NEVER,
+	}
+
+	for in, want := range tests {
+		got, err := ParseColorMode(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseColorMode(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+
+	if _, err := ParseColorMode("sometimes"); err == nil {
+		t.Fatal("expected error for invalid mode")
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	tests := []struct {
+		mode string
+		env  map[string]string
+		tty  bool
+		want bool
+	}{
+		{COLOR_AUTO, nil, true, true},
+		{COLOR_AUTO, nil, false, false},
+		{COLOR_AUTO, map[string]string{"NO_COLOR": "1"}, true, false},
+		{COLOR_AUTO, map[string]string{"CLICOLOR_FORCE": "1"}, false, true},
+		{COLOR_AUTO, map[string]string{"CLICOLOR_FORCE": "0"}, false, false},
+		{COLOR_AUTO, map[string]string{"NO_COLOR": "1", "CLICOLOR_FORCE": "1"}, false, false},
+		{COLOR_ALWAYS, map[string]string{"NO_COLOR": "1"}, false, true},
+		{COLOR_NEVER, map[string]string{"CLICOLOR_FORCE": "1"}, true, false},
+	}
+
+	for _, tt := range tests {
+		withTerminal(t, tt.tty)
+
+		getenv := func(key string) string { return tt.env[key] }
+
+		if got := ColorEnabled(tt.mode, os.Stdout, getenv); got != tt.want {
+			t.Fatalf("ColorEnabled(%q) with env %v, tty %v = %v, want %v", tt.mode, tt.env, tt.tty, got, tt.want)
+		}
+	}
+}
+
+func TestWidth(t *testing.T) {
+	withTerminal(t, false)
+
+	if w := Width(os.Stdout); w != DEFAULT_WIDTH {
+		t.Fatalf("expected default width without terminal, got %d", w)
+	}
+
+	isTerminal = func(*os.File) bool { return true }
+	getSize = func(*os.File) (int, int, error) { return 132, 40, nil }
+
+	if w := Width(os.Stdout); w != 132 {
+		t.Fatalf("expected terminal width, got %d", w)
+	}
+
+	getSize = func(*os.File) (int, int, error) { return 0, 0, errors.New("no size") }
+
+	if w := Width(os.Stdout); w != DEFAULT_WIDTH {
+		t.Fatalf("expected default width on error, got %d", w)
+	}
+}
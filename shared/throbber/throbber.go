@@ -3,6 +3,8 @@ package throbber
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"sync"
 	"time"
 )
@@ -16,6 +18,7 @@ type Throbber struct {
 	cancel     context.CancelFunc
 	isRunning  bool
 	lineLength int
+	writer     io.Writer
 	mu         sync.Mutex
 }
 
@@ -30,6 +33,7 @@ func NewThrobber() *Throbber {
 		cancel:     cancel,
 		isRunning:  false,
 		lineLength: 0,
+		writer:     os.Stderr,
 	}
 }
 
@@ -51,6 +55,12 @@ func (t *Throbber) WithInterval(interval time.Duration) *Throbber {
 	return t
 }
 
+// WithWriter sets where the animation is drawn, stderr by default
+func (t *Throbber) WithWriter(w io.Writer) *Throbber {
+	t.writer = w
+	return t
+}
+
 func (t *Throbber) WithThrob(throb Throb) *Throbber {
 	t.frames = throb.Frames
 	t.interval = time.Duration(throb.Interval) * time.Millisecond
@@ -82,7 +92,7 @@ func (t *Throbber) Start() *Throbber {
 					for j := range blanks {
 						blanks[j] = ' '
 					}
-					fmt.Printf("\r%s\r", string(blanks))
+					fmt.Fprintf(t.writer, "\r%s\r", string(blanks))
 				}
 				t.mu.Unlock()
 				return
@@ -93,7 +103,7 @@ func (t *Throbber) Start() *Throbber {
 				if len(line) > t.lineLength {
 					t.lineLength = len(line)
 				}
-				fmt.Printf("\r%s", line)
+				fmt.Fprintf(t.writer, "\r%s", line)
 				t.mu.Unlock()
 
 				i = (i + 1) % len(t.frames)
@@ -128,5 +138,5 @@ func (t *Throbber) Stop() {
 	for j := range blanks {
 		blanks[j] = ' '
 	}
-	fmt.Printf("\r%s\r", string(blanks))
+	fmt.Fprintf(t.writer, "\r%s\r", string(blanks))
 }
@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mcnull/qai/shared/terminal"
+	"github.com/mcnull/qai/shared/throbber"
+	"github.com/mcnull/qai/shared/utils"
+)
+
+// UI writes everything that is not part of the answer: status messages, progress
+// and diagnostics. It writes to stderr so the answer on stdout can be redirected.
+type UI struct {
+	w io.Writer
+}
+
+// New creates a UI writing to w.
+func New(w io.Writer) *UI {
+	return &UI{w: w}
+}
+
+// Default returns a UI writing to stderr.
+func Default() *UI {
+	return New(os.Stderr)
+}
+
+// Writer returns the underlying writer.
+func (u *UI) Writer() io.Writer {
+	return u.w
+}
+
+// IsTerminal reports whether the UI writes to a terminal.
+func (u *UI) IsTerminal() bool {
+	return terminal.IsTerminal(u.w)
+}
+
+func (u *UI) Printf(format string, a ...any) {
+	fmt.Fprintf(u.w, format, a...)
+}
+
+func (u *UI) Println(a ...any) {
+	fmt.Fprintln(u.w, a...)
+}
+
+// Dump writes v as JSON, used for debug output.
+func (u *UI) Dump(v any) {
+	utils.Fdump(u.w, v)
+}
+
+// StartThrobber starts a throbber with the given message. The animation is only drawn
+// on a terminal; otherwise the returned throbber is never running.
+func (u *UI) StartThrobber(message string, throb throbber.Throb) *throbber.Throbber {
+	t := throbber.NewThrobber().
+		WithMessage(message).
+		WithThrob(throb).
+		WithWriter(u.w)
+
+	if u.IsTerminal() {
+		t.Start()
+	}
+
+	return t
+}
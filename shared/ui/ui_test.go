@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mcnull/qai/shared/throbber"
+)
+
+func TestPrintfWritesToWriter(t *testing.T) {
+	var buf bytes.Buffer
+	u := New(&buf)
+
+	u.Printf("hello %s\n", "world")
+	u.Println("done")
+
+	if got, want := buf.String(), "hello world\ndone\n"; got != want {
+		t.Fatalf("unexpected output: %q, want %q", got, want)
+	}
+}
+
+func TestThrobberSuppressedWithoutTerminal(t *testing.T) {
+	var buf bytes.Buffer
+	u := New(&buf)
+
+	if u.IsTerminal() {
+		t.Fatal("a buffer is not a terminal")
+	}
+
+	th := u.StartThrobber("Working...", throbber.ThrobByName("dots"))
+	if th.IsRunning() {
+		t.Fatal("throbber should not run without a terminal")
+	}
+
+	th.Stop()
+
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output, got %q", buf.String())
+	}
+}
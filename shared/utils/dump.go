@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -14,25 +15,31 @@ import (
 // DumpInColor controls whether Dump and DumpString use colored output
 var DumpInColor = true
 
-// Dump method that accepts anything and dumps the content as a JSON output to the console with colors.
+// Dump method that accepts anything and dumps the content as a JSON output to stderr with colors.
 func Dump(v any) {
-	str := DumpString(v)
+	Fdump(os.Stderr, v)
+}
 
-	// Print the string to stdout
-	if DumpInColor && jsoncolor.IsColorTerminal(os.Stdout) {
-		out := colorable.NewColorable(os.Stdout) // Needed for Windows
-		out.Write([]byte(str))
-	} else {
-		os.Stdout.Write([]byte(str))
+// Fdump dumps v as JSON to w. Colors are only used when w is a color terminal.
+func Fdump(w io.Writer, v any) {
+	color := false
+
+	if f, ok := w.(*os.File); ok && DumpInColor && jsoncolor.IsColorTerminal(f) {
+		color = true
+		w = colorable.NewColorable(f) // Needed for Windows
 	}
 
-	fmt.Println() // Add a newline at the end
+	fmt.Fprintln(w, dumpString(v, color)) // Add a newline at the end
 }
 
 func DumpString(v any) string {
+	return dumpString(v, DumpInColor)
+}
+
+func dumpString(v any, color bool) string {
 	var buf bytes.Buffer
 
-	if DumpInColor {
+	if color {
 		// Create encoder with color support
 		enc := jsoncolor.NewEncoder(&buf)
 		// Set colors similar to jq
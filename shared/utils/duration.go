@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration that is stored in JSON as a string like "30s" or "5m".
+// Plain numbers are accepted as seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(value * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration: %s", string(data))
+	}
+
+	return nil
+}
+
+// Duration returns the value as a time.Duration.
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// JSONSchema describes the accepted JSON values: a duration string or a number of
+// seconds, neither of which can be negative.
+func (Duration) JSONSchema() map[string]any {
+	return map[string]any{
+		"type":    []string{"string", "number"},
+		"pattern": `^(0|([0-9]*(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+)$`,
+		"minimum": 0,
+	}
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDurationUnmarshal(t *testing.T) {
+	var v struct {
+		A Duration `json:"a"`
+		B Duration `json:"b"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"a":"1m30s","b":45}`), &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if v.A.Duration() != 90*time.Second || v.B.Duration() != 45*time.Second {
+		t.Fatalf("unexpected durations: %v, %v", v.A.Duration(), v.B.Duration())
+	}
+
+	if err := json.Unmarshal([]byte(`{"a":"soon"}`), &v); err == nil {
+		t.Fatal("expected error for invalid duration")
+	}
+}
+
+func TestDurationMarshal(t *testing.T) {
+	b, err := json.Marshal(Duration(30 * time.Second))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(b) != `"30s"` {
+		t.Fatalf("unexpected JSON: %s", b)
+	}
+}